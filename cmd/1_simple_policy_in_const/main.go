@@ -5,11 +5,11 @@ import (
 	"fmt"
 	"log"
 
-	"github.com/open-policy-agent/opa/rego"
+	"github.com/olezhek28/access_policy/pkg/policy"
 )
 
 // Определяем Rego-политику как строку
-const policy = `
+const authorizationPolicy = `
 package authorization
 
 default allow = false
@@ -24,16 +24,16 @@ allow {
 }
 `
 
-type authParams struct {
-	role            string
-	experienceYears int
-}
+// Структуры входных данных AccessRequest и Subject генерируются из JSON Schema входных данных политики
+//go:generate go run github.com/olezhek28/access_policy/cmd/inputgen -schema schemas/input.json -out input_gen.go
 
 func main() {
 	ctx := context.Background()
 
-	person1 := authParams{
-		role: "admin",
+	person1 := AccessRequest{
+		Subject: Subject{
+			Role: "admin",
+		},
 	}
 
 	allowed, err := checkAccess(ctx, person1)
@@ -43,9 +43,11 @@ func main() {
 
 	fmt.Printf("Доступ первого человека: %v\n", allowed)
 
-	person2 := authParams{
-		role:            "manager",
-		experienceYears: 3,
+	person2 := AccessRequest{
+		Subject: Subject{
+			Role:            "manager",
+			ExperienceYears: 3,
+		},
 	}
 
 	allowed, err = checkAccess(ctx, person2)
@@ -57,14 +59,9 @@ func main() {
 }
 
 // Функция для выполнения политики
-func checkAccess(ctx context.Context, params authParams) (bool, error) {
-	input := map[string]interface{}{
-		"role":             params.role,
-		"experience_years": params.experienceYears,
-	}
-
-	// Создаем объект rego, который включает в себя политику и запрос к ней
-	regoQuery := rego.New(
+func checkAccess(ctx context.Context, input AccessRequest) (bool, error) {
+	// Создаем движок, который включает в себя политику и запрос к ней
+	engine := policy.New(
 		// Запрос к результату правила allow в пакете authorization.
 		// data:
 		// Пространство политик по-умолчанию.
@@ -74,39 +71,27 @@ func checkAccess(ctx context.Context, params authParams) (bool, error) {
 		// allow:
 		// Секции, которые мы хотим проверить на истинность.
 		// Если истинна хотя бы одна из секций, то результат запроса будет true, иначе false.
-		rego.Query("data.authorization.allow"),
-
+		"data.authorization.allow",
 		// Позволяет определить политику как строку в Go-коде
 		// Первый аргумент:
 		// Имя модуля, которое используется для отладки и в сообщениях об ошибках.
 		// Если политика загружается из файла, то хорошей практикой будет назвать модуль по имени файла, в котором находится политика.
 		// Второй аргумент:
 		// Строка с кодом Rego, и она будет интерпретироваться как политика OPA.
-		rego.Module("authorization_inline.rego", policy),
-
-		// Принимает динамические входные данные для Rego-политики.
-		// Эти данные становятся доступными через переменную input внутри Rego и позволяют создавать гибкие правила,
-		// основанные на изменяющихся значениях.
-		rego.Input(input),
+		policy.WithModule("authorization_inline.rego", authorizationPolicy),
+		// JSON Schema входных данных, из которой сгенерирован AccessRequest
+		policy.WithInputSchema("schemas/input.json"),
 	)
 
-	// Выполняем запрос к политике
-	rs, err := regoQuery.Eval(ctx)
+	// Выполняем запрос к политике.
+	// Входные данные становятся доступными через переменную input внутри Rego и позволяют создавать гибкие правила,
+	// основанные на изменяющихся значениях.
+	decision, err := engine.Eval(ctx, input)
 	if err != nil {
-		return false, fmt.Errorf("ошибка при оценке политики: %w", err)
+		return false, err
 	}
 
-	// Извлекаем результат из запроса.
 	// При простом запросе, как у нас `data.authorization.allow`
-	// ответ будет содержать лишь один элемент в ответе true или false.
-	if len(rs) == 0 || len(rs[0].Expressions) == 0 {
-		return false, nil
-	}
-
-	allowed, ok := rs[0].Expressions[0].Value.(bool)
-	if !ok {
-		return false, fmt.Errorf("невозможно преобразовать результат в bool")
-	}
-
-	return allowed, nil
+	// ответ будет содержать лишь одно значение true или false.
+	return decision.Bool()
 }
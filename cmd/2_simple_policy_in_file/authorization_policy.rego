@@ -2,11 +2,77 @@ package authorization
 
 default allow = false
 
+# Описание ролей из data.roles. Без данных о ролях каждая роль не наследует ничего.
+default roles = {}
+
+roles := data.roles
+
+# Роли из data.roles, их родители и роль пользователя
+role_nodes := {role | roles[role]} | {parent | parent := roles[_].parents[_]} | {role | role := input.role}
+
+# Граф наследования ролей из data.roles: роль -> роли, права которых она наследует.
+# Например admin наследует manager, а manager наследует employee.
+# В граф входят и роли без описания в data.roles, так как graph.reachable возвращает только вершины графа.
+role_graph := {role: object.get(roles, [role, "parents"], []) | role := role_nodes[_]}
+
+# Эффективные роли пользователя: его роль и все роли, которые она наследует транзитивно.
+# Роль, которой нет в data.roles, не наследует ничего.
+effective_roles := graph.reachable(role_graph, {input.role})
+
+# Роли, которые через цепочку родителей наследуют сами себя
+role_cycles[role] {
+    some role
+    parent := role_graph[role][_]
+    graph.reachable(role_graph, {parent})[role]
+}
+
+# Иерархия с циклом считается некорректной, и доступ не выдается никому
 allow {
-    input.role == "admin"
+    count(role_cycles) == 0
+    effective_roles["admin"]
 }
 
 allow {
-    input.role == "manager"
+    count(role_cycles) == 0
+    effective_roles["manager"]
     input.experience_years > 5
 }
+
+# Нарушения в едином для всех проверок формате: code, field, expected, actual, params, hint
+violations := array.concat(array.concat(cycle_violations, role_violations), experience_violations)
+
+cycle_violations := [
+    {
+        "code": "role_cycle",
+        "field": "role",
+        "expected": "acyclic role hierarchy",
+        "actual": role,
+        "hint": sprintf("Role %v inherits itself", [role])
+    } |
+    role := sort(role_cycles)[_]
+]
+
+role_violations := [
+    {
+        "code": "role_insufficient",
+        "field": "role",
+        "expected": ["admin", "manager"],
+        "actual": object.get(input, "role", null),
+        "hint": sprintf("Role %v does not inherit admin or manager", [object.get(input, "role", null)])
+    } |
+    not effective_roles["admin"]
+    not effective_roles["manager"]
+]
+
+experience_violations := [
+    {
+        "code": "experience_insufficient",
+        "field": "experience_years",
+        "expected": 5,
+        "actual": object.get(input, "experience_years", null),
+        "hint": "Manager needs more than 5 years of experience"
+    } |
+    not effective_roles["admin"]
+    effective_roles["manager"]
+    not input.experience_years > 5
+]
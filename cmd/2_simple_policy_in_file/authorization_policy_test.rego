@@ -0,0 +1,74 @@
+package authorization_test
+
+import data.authorization
+
+roles := {
+    "admin": {"parents": ["manager"]},
+    "manager": {"parents": ["employee"]},
+    "employee": {"parents": []}
+}
+
+# Тест: Роль наследует права родителей транзитивно
+test_effective_roles_transitive {
+    result := authorization.effective_roles with input as {"role": "admin"} with data.roles as roles
+    result == {"admin", "manager", "employee"}
+}
+
+# Тест: Роль без описания в data.roles не наследует ничего
+test_effective_roles_unknown_role {
+    result := authorization.effective_roles with input as {"role": "guest"} with data.roles as roles
+    result == {"guest"}
+}
+
+# Тест: Роль, унаследовавшая admin, получает доступ
+test_allow_inherited_admin {
+    authorization.allow with input as {"role": "director"} with data.roles as object.union(roles, {"director": {"parents": ["admin"]}})
+}
+
+# Тест: Родитель без собственного описания в data.roles тоже входит в эффективные роли
+test_effective_roles_undeclared_parent {
+    result := authorization.effective_roles with input as {"role": "intern"} with data.roles as {"intern": {"parents": ["trainee"]}}
+    result == {"intern", "trainee"}
+}
+
+# Тест: Роль employee не получает права manager
+test_deny_employee {
+    not authorization.allow with input as {"role": "employee", "experience_years": 10} with data.roles as roles
+}
+
+# Тест: При цикле в иерархии доступ не выдается
+test_deny_on_cycle {
+    cyclic := object.union(roles, {"employee": {"parents": ["admin"]}})
+
+    authorization.role_cycles == {"admin", "manager", "employee"} with data.roles as cyclic
+    not authorization.allow with input as {"role": "admin"} with data.roles as cyclic
+}
+
+# Тест: Нарушение для роли без прав admin и manager
+test_violations_role_insufficient {
+    result := authorization.violations with input as {"role": "employee"} with data.roles as roles
+    count(result) == 1
+    result[0].code == "role_insufficient"
+    result[0].actual == "employee"
+}
+
+# Тест: Нарушение для manager с недостаточным опытом
+test_violations_experience_insufficient {
+    result := authorization.violations with input as {"role": "manager", "experience_years": 3} with data.roles as roles
+    count(result) == 1
+    result[0].code == "experience_insufficient"
+    result[0].actual == 3
+}
+
+# Тест: Нарушения для ролей в цикле
+test_violations_role_cycle {
+    cyclic := object.union(roles, {"employee": {"parents": ["admin"]}})
+
+    result := authorization.violations with input as {"role": "admin"} with data.roles as cyclic
+    [v.code | v := result[_]] == ["role_cycle", "role_cycle", "role_cycle"]
+}
+
+# Тест: Нет нарушений, когда доступ выдан
+test_no_violations_when_allowed {
+    count(authorization.violations) == 0 with input as {"role": "admin"} with data.roles as roles
+}
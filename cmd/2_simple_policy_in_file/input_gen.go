@@ -0,0 +1,16 @@
+// Code generated by inputgen from schemas/input.json. DO NOT EDIT.
+
+package main
+
+// AccessRequest входные данные политики authorization
+type AccessRequest struct {
+	Subject
+}
+
+// Subject поля входных данных experience_years, role
+type Subject struct {
+	// ExperienceYears опыт работы в годах
+	ExperienceYears int `json:"experience_years"`
+	// Role роль пользователя, например admin или manager
+	Role string `json:"role"`
+}
@@ -2,53 +2,97 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 
-	"github.com/open-policy-agent/opa/rego"
+	"github.com/olezhek28/access_policy/pkg/policy"
+	"github.com/olezhek28/access_policy/pkg/rbac"
 )
 
-type authParams struct {
-	role            string
-	experienceYears int
-}
+// Структуры входных данных AccessRequest и Subject генерируются из JSON Schema входных данных политики
+//go:generate go run github.com/olezhek28/access_policy/cmd/inputgen -schema schemas/input.json -out input_gen.go
 
 func main() {
 	ctx := context.Background()
 
-	person1 := authParams{
-		role: "admin",
+	// Иерархия ролей описана в данных: admin наследует manager, а manager наследует employee
+	roles, err := rbac.LoadFile("roles.json")
+	if err != nil {
+		log.Fatalf("ошибка при загрузке ролей: %v", err)
+	}
+
+	// Роли можно регистрировать и из кода: director получает всё, что есть у admin
+	if err = roles.Register("director", "admin"); err != nil {
+		log.Fatalf("ошибка при регистрации роли: %v", err)
+	}
+
+	// Связь, которая замыкает цикл, не регистрируется
+	if err = roles.Register("employee", "director"); err != nil {
+		fmt.Printf("Роль не зарегистрирована: %v\n", err)
+	}
+
+	person1 := AccessRequest{
+		Subject: Subject{
+			Role: "admin",
+		},
 	}
 
-	allowed, err := checkAccess(ctx, person1)
+	allowed, err := checkAccess(ctx, roles, person1)
 	if err != nil {
 		log.Fatalf("ошибка при проверке доступа: %v", err)
 	}
 
 	fmt.Printf("Доступ первого человека: %v\n", allowed)
 
-	person2 := authParams{
-		role:            "manager",
-		experienceYears: 3,
+	person2 := AccessRequest{
+		Subject: Subject{
+			Role:            "manager",
+			ExperienceYears: 3,
+		},
 	}
 
-	allowed, err = checkAccess(ctx, person2)
+	allowed, err = checkAccess(ctx, roles, person2)
 	if err != nil {
 		log.Fatalf("ошибка при проверке доступа: %v", err)
 	}
 
 	fmt.Printf("Доступ второго человека: %v\n", allowed)
-}
 
-// Функция для выполнения политики
-func checkAccess(ctx context.Context, params authParams) (bool, error) {
-	input := map[string]interface{}{
-		"role":             params.role,
-		"experience_years": params.experienceYears,
+	person3 := AccessRequest{
+		Subject: Subject{
+			Role: "director",
+		},
+	}
+
+	allowed, err = checkAccess(ctx, roles, person3)
+	if err != nil {
+		log.Fatalf("ошибка при проверке доступа: %v", err)
 	}
 
-	// Создаем объект rego, который включает в себя политику и запрос к ней
-	regoQuery := rego.New(
+	fmt.Printf("Доступ третьего человека (роли %v): %v\n", roles.Effective(person3.Role), allowed)
+
+	person4 := AccessRequest{
+		Subject: Subject{
+			Role:            "manager",
+			ExperienceYears: -1,
+		},
+	}
+
+	// Входные данные, не прошедшие проверку по схеме, не вычисляются: это ошибка, а не отказ в доступе
+	_, err = checkAccess(ctx, roles, person4)
+	var inputErr *policy.InputError
+	if !errors.As(err, &inputErr) {
+		log.Fatalf("ожидалась ошибка входных данных, получено: %v", err)
+	}
+
+	fmt.Printf("Данные четвертого человека не прошли проверку: %v\n", inputErr)
+}
+
+// Функция для выполнения политики
+func checkAccess(ctx context.Context, roles *rbac.Roles, input AccessRequest) (bool, error) {
+	// Создаем движок, который включает в себя политику и запрос к ней
+	engine := policy.New(
 		// Запрос к результату правила allow в пакете authorization.
 		// data:
 		// Пространство политик по-умолчанию.
@@ -58,41 +102,26 @@ func checkAccess(ctx context.Context, params authParams) (bool, error) {
 		// allow:
 		// Секции, которые мы хотим проверить на истинность.
 		// Если истинна хотя бы одна из секций, то результат запроса будет true, иначе false.
-		rego.Query("data.authorization.allow"),
-
-		// В отличие от rego.Module, который принимает политику как строку,
-		// rego.Load ищет и загружает Rego-файлы по заданным путям.
+		"data.authorization.allow",
+		// В отличие от policy.WithModule, который принимает политику как строку,
+		// policy.WithFiles ищет и загружает Rego-файлы по заданным путям.
 		// Это полезно для организации больших проектов, где политики хранятся в отдельных файлах.
-		// Первый аргумент:
-		// Имя файла с политикой.
-		// Второй аргумент:
-		// Дополнительные опции загрузки, которые можно настроить, например,
-		// для включения и исключения определенных файлов. Можно передать nil, если нет особых требований.
-		rego.Load([]string{"authorization_policy.rego"}, nil), // Загрузка политики из файла
-
-		// Принимает динамические входные данные для Rego-политики.
-		// Эти данные становятся доступными через переменную input внутри Rego и позволяют создавать гибкие правила,
-		// основанные на изменяющихся значениях.
-		rego.Input(input),
+		policy.WithFiles("authorization_policy.rego"), // Загрузка политики из файла
+		// Иерархия ролей доступна в политике как data.roles
+		policy.WithDataProvider("roles", roles),
+		// JSON Schema входных данных: роль - непустая строка, опыт - неотрицательное целое число
+		policy.WithInputSchema("schemas/input.json"),
 	)
 
-	// Выполняем запрос к политике
-	rs, err := regoQuery.Eval(ctx)
+	// Выполняем запрос к политике.
+	// Входные данные становятся доступными через переменную input внутри Rego и позволяют создавать гибкие правила,
+	// основанные на изменяющихся значениях.
+	decision, err := engine.Eval(ctx, input)
 	if err != nil {
-		return false, fmt.Errorf("ошибка при оценке политики: %w", err)
+		return false, err
 	}
 
-	// Извлекаем результат из запроса.
 	// При простом запросе, как у нас `data.authorization.allow`
-	// ответ будет содержать лишь один элемент в ответе true или false.
-	if len(rs) == 0 || len(rs[0].Expressions) == 0 {
-		return false, nil
-	}
-
-	allowed, ok := rs[0].Expressions[0].Value.(bool)
-	if !ok {
-		return false, fmt.Errorf("невозможно преобразовать результат в bool")
-	}
-
-	return allowed, nil
+	// ответ будет содержать лишь одно значение true или false.
+	return decision.Bool()
 }
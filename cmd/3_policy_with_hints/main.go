@@ -2,27 +2,40 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"flag"
 	"fmt"
+	"log"
+	"sort"
 
 	"github.com/fatih/color"
-	"github.com/open-policy-agent/opa/rego"
+	"github.com/olezhek28/access_policy/pkg/policy"
 )
 
-const (
-	isValidKey    = "is_valid"
-	mismatchesKey = "mismatches"
+// resourceStatus Итоговый статус политики resource_check.resource_status
+type resourceStatus struct {
+	IsValid bool `rego:"is_valid"`
+	// Mismatches несоответствия полей ресурса с подсказками. Ожидаемое и фактическое значения
+	// могут быть любым JSON-значением, а для поля, которого нет во входных данных, код несоответствия input_missing.
+	Mismatches []policy.Violation `rego:"mismatches"`
+}
 
-	fieldKey    = "field"
-	expectedKey = "expected"
-	actualKey   = "actual"
-	hintKey     = "hint"
-)
+var lang = flag.String("lang", "ru", "язык сообщений о несоответствиях, например ru или en")
 
 func main() {
+	flag.Parse()
+
 	ctx := context.Background()
 
+	// Сообщения о несоответствиях берутся из каталога по коду несоответствия.
+	// Если для языка нет сообщения, выводится подсказка из политики.
+	catalog, err := policy.LoadCatalog("../../messages")
+	if err != nil {
+		log.Fatalf("ошибка при загрузке каталога сообщений: %v", err)
+	}
+
 	//testCheckAccess(ctx)
-	testCheckAccessWithDetails(ctx)
+	testCheckAccessWithDetails(ctx, catalog)
 }
 
 func testCheckAccess(ctx context.Context) {
@@ -64,9 +77,9 @@ func testCheckAccess(ctx context.Context) {
 }
 
 func checkAccess(ctx context.Context, inputData map[string]interface{}) (bool, error) {
-	// Создаем объект rego, который включает в себя политику и запрос к ней
-	query, err := rego.New(
-		// Запрос к результату правила allow в пакете authorization.
+	// Создаем движок, который включает в себя политику и запрос к ней
+	engine := policy.New(
+		// Запрос к результату правила resourceCondition в пакете resource_check.
 		// data:
 		// Пространство политик по-умолчанию.
 		// Всё что описано в файле политики, доступно через data, если специально не задавать кастомное пространство.
@@ -74,43 +87,25 @@ func checkAccess(ctx context.Context, inputData map[string]interface{}) (bool, e
 		// Пакет, в котором находится политика. Задается в поле package политики.
 		// resourceCondition:
 		// Именованное правило, в результате которого лежит финальный ответ по вопросу доступа.
-		rego.Query("data.resource_check.resourceCondition"),
-		// В отличие от rego.Module, который принимает политику как строку,
-		// rego.Load ищет и загружает Rego-файлы по заданным путям.
+		"data.resource_check.resourceCondition",
+		// policy.WithFiles ищет и загружает Rego-файлы по заданным путям.
 		// Это полезно для организации больших проектов, где политики хранятся в отдельных файлах.
-		// Первый аргумент:
-		// Имя файла с политикой.
-		// Второй аргумент:
-		// Дополнительные опции загрузки, которые можно настроить, например,
-		// для включения и исключения определенных файлов. Можно передать nil, если нет особых требований.
-		rego.Load([]string{"./resource_check.rego"}, nil),
-	).
-		// Метод PrepareForEval используется для предварительной подготовки
-		// запроса, чтобы его можно было повторно использовать с разными входными
-		// данными без необходимости заново загружать и компилировать политику каждый раз.
-		PrepareForEval(ctx)
-	if err != nil {
-		return false, fmt.Errorf("ошибка при компиляции политики: %w", err)
-	}
+		policy.WithFiles("./resource_check.rego"),
+	)
 
 	// Выполнение запроса
-	rs, err := query.Eval(ctx, rego.EvalInput(inputData))
+	decision, err := engine.Eval(ctx, inputData)
 	if err != nil {
-		return false, fmt.Errorf("ошибка при оценке политики: %w", err)
+		return false, err
 	}
-	if len(rs) == 0 {
-		return false, fmt.Errorf("политика не вернула результат")
-	}
-
-	result, ok := rs[0].Expressions[0].Value.(bool)
-	if !ok {
-		return false, fmt.Errorf("невозможно преобразовать результат в bool")
+	if !decision.Defined {
+		return false, policy.ErrUndefined
 	}
 
-	return result, nil
+	return decision.Bool()
 }
 
-func testCheckAccessWithDetails(ctx context.Context) {
+func testCheckAccessWithDetails(ctx context.Context, catalog *policy.Catalog) {
 	inputData := []map[string]interface{}{
 		{
 			"source_uuid": "0FF8AFB4-55D2-4836-B17C-643AD59BBB2F",
@@ -128,11 +123,21 @@ func testCheckAccessWithDetails(ctx context.Context) {
 			"source_uuid": "invalid_uuid",
 			"source_slug": "invalid_slug",
 		},
+		{
+			"source_uuid": 42,
+			"source_slug": []string{"some_slug"},
+		},
+		{
+			"source_uuid": "0FF8AFB4-55D2-4836-B17C-643AD59BBB2F",
+		},
+		{
+			"source_uuid": nil,
+		},
 	}
 
 	for i, data := range inputData {
 		fmt.Printf(color.BlueString("Проверка доступа %d:\n"), i+1)
-		allowed, details, err := checkAccessWithDetails(ctx, data)
+		allowed, details, err := checkAccessWithDetails(ctx, catalog, data)
 		if err != nil {
 			fmt.Printf("Ошибка при проверке доступа: %v\n", err)
 			continue
@@ -142,8 +147,14 @@ func testCheckAccessWithDetails(ctx context.Context) {
 			fmt.Println(color.GreenString("Ресурс валиден"))
 		} else {
 			fmt.Println(color.RedString("Ресурс не валиден"))
-			for field, detail := range details {
-				fmt.Printf("- %s: %s\n", field, detail)
+			fields := make([]string, 0, len(details))
+			for field := range details {
+				fields = append(fields, field)
+			}
+			sort.Strings(fields)
+
+			for _, field := range fields {
+				fmt.Printf("- %s: %s\n", field, details[field])
 			}
 		}
 
@@ -151,10 +162,10 @@ func testCheckAccessWithDetails(ctx context.Context) {
 	}
 }
 
-func checkAccessWithDetails(ctx context.Context, inputData map[string]interface{}) (bool, map[string]string, error) {
-	// Создаем объект rego, который включает в себя политику и запрос к ней
-	query, err := rego.New(
-		// Запрос к результату правила allow в пакете authorization.
+func checkAccessWithDetails(ctx context.Context, catalog *policy.Catalog, inputData map[string]interface{}) (bool, map[string]string, error) {
+	// Создаем движок, который включает в себя политику и запрос к ней
+	engine := policy.New(
+		// Запрос к результату правила resource_status в пакете resource_check.
 		// data:
 		// Пространство политик по-умолчанию.
 		// Всё что описано в файле политики, доступно через data, если специально не задавать кастомное пространство.
@@ -162,82 +173,51 @@ func checkAccessWithDetails(ctx context.Context, inputData map[string]interface{
 		// Пакет, в котором находится политика. Задается в поле package политики.
 		// resource_status:
 		// Именованное правило, в результате которого лежит финальный ответ по вопросу доступа.
-		rego.Query("data.resource_check.resource_status"),
-		// В отличие от rego.Module, который принимает политику как строку,
-		// rego.Load ищет и загружает Rego-файлы по заданным путям.
+		"data.resource_check.resource_status",
+		// policy.WithFiles ищет и загружает Rego-файлы по заданным путям.
 		// Это полезно для организации больших проектов, где политики хранятся в отдельных файлах.
-		// Первый аргумент:
-		// Имя файла с политикой.
-		// Второй аргумент:
-		// Дополнительные опции загрузки, которые можно настроить, например,
-		// для включения и исключения определенных файлов. Можно передать nil, если нет особых требований.
-		rego.Load([]string{"./resource_check_with_details.rego"}, nil),
-	).
-		// Метод PrepareForEval используется для предварительной подготовки
-		// запроса, чтобы его можно было повторно использовать с разными входными
-		// данными без необходимости заново загружать и компилировать политику каждый раз.
-		PrepareForEval(ctx)
-	if err != nil {
-		return false, nil, fmt.Errorf("ошибка при компиляции политики: %w", err)
-	}
+		policy.WithFiles("./resource_check_with_details.rego"),
+	)
 
 	// Выполнение запроса
-	rs, err := query.Eval(ctx, rego.EvalInput(inputData))
+	decision, err := engine.Eval(ctx, inputData)
 	if err != nil {
-		return false, nil, fmt.Errorf("ошибка при оценке политики: %w", err)
-	}
-	if len(rs) == 0 {
-		return false, nil, fmt.Errorf("политика не вернула результат")
-	}
-
-	result, ok := rs[0].Expressions[0].Value.(map[string]interface{})
-	if !ok {
-		return false, nil, fmt.Errorf("невозможно преобразовать результат в map[string]interface{}")
+		return false, nil, err
 	}
 
-	isValid, ok := result[isValidKey].(bool)
-	if !ok {
-		return false, nil, fmt.Errorf("невозможно преобразовать %s в bool", isValidKey)
+	status, err := policy.Decode[resourceStatus](decision)
+	if err != nil {
+		return false, nil, err
 	}
 
-	if isValid {
+	if status.IsValid {
 		return true, nil, nil
 	}
 
-	mismatches, ok := result[mismatchesKey].([]interface{})
-	if !ok {
-		return false, nil, fmt.Errorf("невозможно преобразовать %s в []interface{}", mismatchesKey)
-	}
-
-	details := make(map[string]string, len(mismatches))
-	for _, mismatch := range mismatches {
-		detail, okDetail := mismatch.(map[string]interface{})
-		if !okDetail {
-			return false, nil, fmt.Errorf("невозможно преобразовать %s в map[string]interface{}", mismatchesKey)
-		}
-
-		fieldName, okDetail := detail[fieldKey].(string)
-		if !okDetail {
-			return false, nil, fmt.Errorf("невозможно преобразовать %s в string", fieldKey)
+	details := make(map[string]string, len(status.Mismatches))
+	for _, m := range status.Mismatches {
+		actual := formatValue(m.Actual)
+		if m.Code == "input_missing" {
+			actual = "поле не передано"
 		}
 
-		expected, okDetail := detail[expectedKey].(string)
-		if !okDetail {
-			return false, nil, fmt.Errorf("невозможно преобразовать %s в string", expectedKey)
-		}
+		details[m.Field] = fmt.Sprintf("Ожидалось: %s, Получено: %s, Подсказка: %v",
+			formatValue(m.Expected), actual, catalog.Message(*lang, m))
+	}
 
-		actual, okDetail := detail[actualKey].(string)
-		if !okDetail {
-			return false, nil, fmt.Errorf("невозможно преобразовать %s в string", actualKey)
-		}
+	return false, details, nil
+}
 
-		hint, okDetail := detail[hintKey].(string)
-		if !okDetail {
-			return false, nil, fmt.Errorf("невозможно преобразовать %s в string", hintKey)
-		}
+// formatValue выводит JSON-значение из результата политики: строки как есть, остальное в JSON
+func formatValue(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
 
-		details[fieldName] = fmt.Sprintf("Ожидалось: %v, Получено: %v, Подсказка: %v", expected, actual, hint)
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
 	}
 
-	return false, details, nil
+	return string(raw)
 }
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/olezhek28/access_policy/pkg/policy"
+	"github.com/olezhek28/access_policy/pkg/policy/policytest"
+)
+
+// TestPolicies выполняет тесты политики resource_check_with_details.rego. Политика resource_check.rego
+// объявляет тот же пакет, поэтому загружаются только файлы политики с подсказками и ее тестов.
+func TestPolicies(t *testing.T) {
+	policytest.Run(t, []string{"resource_check_with_details.rego", "resource_check_with_details_test.rego"})
+}
+
+func TestCheckAccessWithDetails(t *testing.T) {
+	ctx := context.Background()
+
+	catalog, err := policy.LoadCatalog("../../messages")
+	if err != nil {
+		t.Fatalf("ошибка при загрузке каталога сообщений: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		input map[string]interface{}
+		want  map[string]string
+	}{
+		{
+			name: "ресурс валиден",
+			input: map[string]interface{}{
+				"source_uuid": "0FF8AFB4-55D2-4836-B17C-643AD59BBB2F",
+				"source_slug": "some_slug",
+			},
+		},
+		{
+			name:  "не передан source_slug",
+			input: map[string]interface{}{"source_uuid": "0FF8AFB4-55D2-4836-B17C-643AD59BBB2F"},
+			want: map[string]string{
+				"source_slug": "Ожидалось: some_slug, Получено: поле не передано, Подсказка: Не передано поле source_slug, ожидалось значение some_slug",
+			},
+		},
+		{
+			name: "число в source_uuid",
+			input: map[string]interface{}{
+				"source_uuid": 42,
+				"source_slug": "some_slug",
+			},
+			want: map[string]string{
+				"source_uuid": "Ожидалось: 0FF8AFB4-55D2-4836-B17C-643AD59BBB2F, Получено: 42, Подсказка: Для поля source_uuid ожидалось значение 0FF8AFB4-55D2-4836-B17C-643AD59BBB2F, получено 42",
+			},
+		},
+		{
+			name: "массив в source_slug",
+			input: map[string]interface{}{
+				"source_uuid": "0FF8AFB4-55D2-4836-B17C-643AD59BBB2F",
+				"source_slug": []string{"some_slug"},
+			},
+			want: map[string]string{
+				"source_slug": `Ожидалось: some_slug, Получено: ["some_slug"], Подсказка: Для поля source_slug ожидалось значение some_slug, получено ["some_slug"]`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			allowed, details, err := checkAccessWithDetails(ctx, catalog, tt.input)
+			if err != nil {
+				t.Fatalf("checkAccessWithDetails: %v", err)
+			}
+
+			if allowed != (tt.want == nil) {
+				t.Errorf("allowed = %v, несоответствия %v", allowed, details)
+			}
+			for field, want := range tt.want {
+				if details[field] != want {
+					t.Errorf("%s:\nполучено  %q\nожидалось %q", field, details[field], want)
+				}
+			}
+			if len(details) != len(tt.want) {
+				t.Errorf("несоответствия %v, ожидались %v", details, tt.want)
+			}
+		})
+	}
+}
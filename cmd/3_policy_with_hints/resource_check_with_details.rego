@@ -5,17 +5,41 @@ policy_resource := {
     "source_slug": "some_slug"
 }
 
-# Массив несоответствий с подсказками
-mismatches := [
-    {
+# Массив несоответствий с кодом для каталога сообщений и подсказкой на случай, если сообщения в каталоге нет.
+# Проверяются все поля ресурса: поле, которого нет во входных данных, - отдельное несоответствие input_missing,
+# а значения сравниваются независимо от типа (строка, число, null, массив и т.д.).
+mismatches := [mismatch |
+    some key
+    expected := policy_resource[key]
+    mismatch := field_mismatch(key, expected)
+]
+
+field_mismatch(key, expected) = mismatch {
+    not has_key(input, key)
+    mismatch := {
+        "code": "input_missing",
         "field": key,
-        "actual": input[key],
-        "expected": policy_resource[key],
-        "hint": sprintf("Expected %v for %v, but got %v", [policy_resource[key], key, input[key]])
-    } |
+        "expected": expected,
+        "actual": null,
+        "hint": sprintf("Field %v is missing, expected %v", [key, expected])
+    }
+}
 
-    policy_resource[key] != input[key]
-]
+field_mismatch(key, expected) = mismatch {
+    actual := input[key]
+    actual != expected
+    mismatch := {
+        "code": "resource_field_mismatch",
+        "field": key,
+        "expected": expected,
+        "actual": actual,
+        "hint": sprintf("Expected %v for %v, but got %v", [expected, key, actual])
+    }
+}
+
+has_key(obj, key) {
+    _ = obj[key]
+}
 
 # Итоговый статус
 resource_status := {
@@ -0,0 +1,63 @@
+package resource_check_with_details_test
+
+import data.resource_check
+
+valid_input := {
+    "source_uuid": "0FF8AFB4-55D2-4836-B17C-643AD59BBB2F",
+    "source_slug": "some_slug"
+}
+
+# Тест: Ресурс валиден, если все поля совпадают
+test_resource_valid {
+    status := resource_check.resource_status with input as valid_input
+    status.is_valid
+    count(status.mismatches) == 0
+}
+
+# Тест: Поле, которого нет во входных данных, - несоответствие input_missing, а не ошибка проверки
+test_missing_source_slug {
+    status := resource_check.resource_status with input as {"source_uuid": "0FF8AFB4-55D2-4836-B17C-643AD59BBB2F"}
+    not status.is_valid
+    count(status.mismatches) == 1
+
+    mismatch := status.mismatches[0]
+    mismatch.code == "input_missing"
+    mismatch.field == "source_slug"
+    mismatch.expected == "some_slug"
+    mismatch.actual == null
+    mismatch.hint == "Field source_slug is missing, expected some_slug"
+}
+
+# Тест: Число вместо строки - несоответствие со значением как есть
+test_numeric_source_uuid {
+    status := resource_check.resource_status with input as object.union(valid_input, {"source_uuid": 42})
+    not status.is_valid
+    count(status.mismatches) == 1
+
+    mismatch := status.mismatches[0]
+    mismatch.code == "resource_field_mismatch"
+    mismatch.field == "source_uuid"
+    mismatch.actual == 42
+    mismatch.hint == "Expected 0FF8AFB4-55D2-4836-B17C-643AD59BBB2F for source_uuid, but got 42"
+}
+
+# Тест: Массив с ожидаемым значением не равен самому значению
+test_array_source_slug {
+    status := resource_check.resource_status with input as object.union(valid_input, {"source_slug": ["some_slug"]})
+    not status.is_valid
+    count(status.mismatches) == 1
+
+    mismatch := status.mismatches[0]
+    mismatch.code == "resource_field_mismatch"
+    mismatch.field == "source_slug"
+    mismatch.actual == ["some_slug"]
+}
+
+# Тест: null в поле - несоответствие, а не отсутствующее поле
+test_null_source_uuid {
+    status := resource_check.resource_status with input as {"source_uuid": null}
+    count(status.mismatches) == 2
+
+    codes := {field: m.code | m := status.mismatches[_]; field := m.field}
+    codes == {"source_uuid": "resource_field_mismatch", "source_slug": "input_missing"}
+}
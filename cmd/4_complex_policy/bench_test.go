@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	"github.com/olezhek28/access_policy/pkg/policy"
+	"github.com/olezhek28/access_policy/pkg/policy/cases"
+)
+
+// BenchmarkCheckAccessPrepared задержка проверки доступа с запросом, подготовленным один раз
+// и вызываемым конкурентно
+func BenchmarkCheckAccessPrepared(b *testing.B) {
+	ctx := context.Background()
+	testCases := loadBenchCases(b)
+
+	engine := newEngine()
+	if err := engine.Prepare(ctx); err != nil {
+		b.Fatalf("ошибка при подготовке политики: %v", err)
+	}
+	b.ResetTimer()
+
+	var counter atomic.Int64
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			i := counter.Add(1)
+			mustCheckAccess(ctx, b, engine, testCases[int(i)%len(testCases)].Input)
+		}
+	})
+}
+
+// BenchmarkCheckAccessRecompile задержка проверки доступа с компиляцией политики на каждый запрос,
+// как работали примеры до появления подготовленного запроса
+func BenchmarkCheckAccessRecompile(b *testing.B) {
+	ctx := context.Background()
+	testCases := loadBenchCases(b)
+	b.ResetTimer()
+
+	var counter atomic.Int64
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			i := counter.Add(1)
+			mustCheckAccess(ctx, b, newEngine(), testCases[int(i)%len(testCases)].Input)
+		}
+	})
+}
+
+func loadBenchCases(b *testing.B) []cases.Case[AccessRequest] {
+	testCases, err := cases.Load[AccessRequest]("cases.yaml")
+	if err != nil {
+		b.Fatalf("ошибка при загрузке кейсов: %v", err)
+	}
+
+	return testCases
+}
+
+func mustCheckAccess(ctx context.Context, b *testing.B, engine *policy.Engine, input AccessRequest) {
+	// Отклоненные по схеме входные данные - ожидаемый результат проверки, а не ошибка бенчмарка.
+	// Вызывается из горутин RunParallel, поэтому ошибка не прерывает бенчмарк через Fatal.
+	var inputErr *policy.InputError
+	if _, err := checkAccess(ctx, engine, input); err != nil && !errors.As(err, &inputErr) {
+		b.Errorf("ошибка при проверке доступа: %v", err)
+	}
+}
+
+// checkAccess проверяет доступ подготовленным движком и декодирует итоговый результат политики
+func checkAccess(ctx context.Context, engine *policy.Engine, input AccessRequest) (result, error) {
+	// Выполнение запроса
+	decision, err := engine.Eval(ctx, input)
+	if err != nil {
+		return result{}, err
+	}
+
+	return policy.Decode[result](decision)
+}
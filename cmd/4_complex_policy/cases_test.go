@@ -0,0 +1,12 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/olezhek28/access_policy/pkg/policy/policytest"
+)
+
+// TestCases выполняет кейсы из cases.yaml тем же движком, что и main: каждый кейс - отдельный подтест
+func TestCases(t *testing.T) {
+	policytest.RunCases[AccessRequest](t, newEngine(), "cases.yaml")
+}
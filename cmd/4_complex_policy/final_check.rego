@@ -13,10 +13,13 @@ accessAllowed {
     print("permissionsGranted:", permission_check.permissionsGranted)
 }
 
-# Диагностическая информация о недостающих правах или несоответствии ресурса
+# Диагностическая информация о недостающих правах или несоответствии ресурса.
+# violations собирает нарушения всех проверок в едином формате.
 result = {
+    "action": permission_check.action,
     "access_allowed": accessAllowed,
     "resource_valid": resource_check.resourceCondition,
     "permissions_granted": permission_check.permissionsGranted,
-    "missing_permissions": permission_check.missingPermissions
+    "missing_permissions": permission_check.missingPermissions,
+    "violations": array.concat(resource_check.violations, permission_check.violations)
 }
@@ -5,6 +5,7 @@ import data.final_check
 # Тест: Доступ разрешен, когда ресурс валиден и все права имеются
 test_access_allowed_when_resource_and_permissions_valid {
     input := {
+        "action": "update",
         "source_uuid": "0FF8AFB4-55D2-4836-B17C-643AD59BBB2F",
         "source_slug": "some_slug",
         "user_permissions": ["read", "write"]
@@ -21,6 +22,7 @@ test_access_allowed_when_resource_and_permissions_valid {
 # Тест: Доступ запрещен, когда ресурс не валиден
 test_access_denied_when_resource_invalid {
     input := {
+        "action": "update",
         "source_uuid": "incorrect_uuid",
         "source_slug": "some_slug",
         "user_permissions": ["read", "write"]
@@ -37,6 +39,7 @@ test_access_denied_when_resource_invalid {
 # Тест: Доступ запрещен, когда у пользователя отсутствуют необходимые права
 test_access_denied_when_permissions_missing {
     input := {
+        "action": "update",
         "source_uuid": "0FF8AFB4-55D2-4836-B17C-643AD59BBB2F",
         "source_slug": "some_slug",
         "user_permissions": ["read"]  # Отсутствует право "write"
@@ -53,6 +56,7 @@ test_access_denied_when_permissions_missing {
 # Тест: Доступ запрещен, когда ни ресурс, ни права не валидны
 test_access_denied_when_resource_and_permissions_invalid {
     input := {
+        "action": "update",
         "source_uuid": "incorrect_uuid",
         "source_slug": "incorrect_slug",
         "user_permissions": ["read"]  # Отсутствует право "write"
@@ -65,3 +69,33 @@ test_access_denied_when_resource_and_permissions_invalid {
     not result.permissions_granted  # Права не должны быть предоставлены
     result.missing_permissions == {"write"}  # "write" должно быть в недостающих правах
 }
+
+# Тест: Результат сообщает, для какого действия выполнена проверка
+test_result_reports_action {
+    input := {
+        "action": "read",
+        "source_uuid": "0FF8AFB4-55D2-4836-B17C-643AD59BBB2F",
+        "source_slug": "some_slug",
+        "user_permissions": ["read"]
+    }
+
+    result := final_check.result with input as input
+
+    result.action == "read"
+    result.access_allowed
+}
+
+# Тест: Результат собирает нарушения всех проверок
+test_result_aggregates_violations {
+    input := {
+        "action": "update",
+        "source_uuid": "0FF8AFB4-55D2-4836-B17C-643AD59BBB2F",
+        "source_slug": "incorrect_slug",
+        "user_permissions": ["read"]
+    }
+
+    result := final_check.result with input as input
+
+    codes := [v.code | v := result.violations[_]]
+    codes == ["resource_field_mismatch", "permission_missing"]
+}
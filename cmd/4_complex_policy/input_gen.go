@@ -0,0 +1,26 @@
+// Code generated by inputgen from schemas/input.json. DO NOT EDIT.
+
+package main
+
+// AccessRequest входные данные политики final_check
+type AccessRequest struct {
+	ResourceRef
+	Subject
+
+	// Action действие над ресурсом, например read или update
+	Action string `json:"action"`
+}
+
+// ResourceRef поля входных данных source_slug, source_uuid
+type ResourceRef struct {
+	// SourceSlug slug ресурса
+	SourceSlug string `json:"source_slug"`
+	// SourceUUID идентификатор ресурса в формате UUID. Сравнивается с учетом регистра, как он записан в данных ресурса
+	SourceUUID string `json:"source_uuid"`
+}
+
+// Subject поля входных данных user_permissions
+type Subject struct {
+	// UserPermissions права пользователя, например read или documents:*
+	UserPermissions []string `json:"user_permissions"`
+}
@@ -2,108 +2,143 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
+	"log/slog"
+	"os"
 
 	"github.com/fatih/color"
-	"github.com/open-policy-agent/opa/rego"
+	"github.com/olezhek28/access_policy/pkg/policy"
+	"github.com/olezhek28/access_policy/pkg/policy/cases"
 )
 
-const (
-	accessAllowedKey      = "access_allowed"
-	resourceValidKey      = "resource_valid"
-	permissionsGrantedKey = "permissions_granted"
-	missingPermissionsKey = "missing_permissions"
-)
-
-type teatCase struct {
-	name  string
-	input map[string]interface{}
-}
+// Структуры входных данных AccessRequest, ResourceRef и Subject генерируются из JSON Schema входных данных политики
+//go:generate go run github.com/olezhek28/access_policy/cmd/inputgen -schema schemas/input.json -out input_gen.go
 
+// result Итоговый результат политики final_check.result
 type result struct {
-	accessAllowed      bool
-	resourceValid      bool
-	permissionsGranted bool
-	missingPermissions []string
+	Action             string   `rego:"action"`
+	AccessAllowed      bool     `rego:"access_allowed"`
+	ResourceValid      bool     `rego:"resource_valid"`
+	PermissionsGranted bool     `rego:"permissions_granted"`
+	MissingPermissions []string `rego:"missing_permissions"`
+	// Violations нарушения всех проверок в едином формате
+	Violations []policy.Violation `rego:"violations"`
 }
 
+var (
+	printOut = flag.Bool("print", false, "выводить print() из политик в лог")
+	lang     = flag.String("lang", "ru", "язык сообщений о нарушениях, например ru или en")
+	explain  = flag.Bool("explain", false, "при отказе в доступе выводить дерево вычисленных правил с невыполнившимися выражениями")
+	// casesPath файл кейсов: входные данные и ожидаемый результат политики для каждого кейса
+	casesPath = flag.String("cases", "cases.yaml", "YAML/JSON-файл с кейсами проверки доступа")
+)
+
 func main() {
+	flag.Parse()
+
 	ctx := context.Background()
 
-	// Входные данные для проверки
-	inputData := []teatCase{
-		{
-			name: "Доступ разрешен, все параметры валидны",
-			input: map[string]interface{}{
-				"source_uuid":      "0FF8AFB4-55D2-4836-B17C-643AD59BBB2F",
-				"source_slug":      "some_slug",
-				"user_permissions": []string{"read", "write"},
-			},
-		},
-		{
-			name: "Доступ разрешен, все параметры валидны, но права не в том регистре",
-			input: map[string]interface{}{
-				"source_uuid":      "0FF8AFB4-55D2-4836-B17C-643AD59BBB2F",
-				"source_slug":      "some_slug",
-				"user_permissions": []string{"Read", "wRite"},
-			},
-		},
-		{
-			name: "Доступ запрещен, идентификатор ресурса не валиден",
-			input: map[string]interface{}{
-				"source_uuid":      "invalid_uuid",
-				"source_slug":      "some_slug",
-				"user_permissions": []string{"read", "write"},
-			},
-		},
-		{
-			name: "Доступ запрещен, slug ресурса не валиден",
-			input: map[string]interface{}{
-				"source_uuid":      "0FF8AFB4-55D2-4836-B17C-643AD59BBB2F",
-				"source_slug":      "invalid_slug",
-				"user_permissions": []string{"read", "write"},
-			},
-		},
-		{
-			name: "Доступ запрещен, недостаточно прав доступа к ресурсу",
-			input: map[string]interface{}{
-				"source_uuid":      "0FF8AFB4-55D2-4836-B17C-643AD59BBB2F",
-				"source_slug":      "some_slug",
-				"user_permissions": []string{"write"},
-			},
-		},
+	// Кейсы проверки доступа: входные данные и ожидаемый результат политики
+	testCases, err := cases.Load[AccessRequest](*casesPath)
+	if err != nil {
+		fmt.Printf("Ошибка при загрузке кейсов: %v\n", err)
+		return
 	}
 
-	for _, data := range inputData {
-		fmt.Printf(color.BlueString("Кейс: \"%s\":\n", data.name))
-		allowed, err := checkAccess(ctx, data)
+	engine := newEngine()
+	if err := engine.Prepare(ctx); err != nil {
+		fmt.Printf("Ошибка при подготовке политики: %v\n", err)
+		return
+	}
+
+	// Сообщения о нарушениях берутся из каталога по коду нарушения на языке из флага -lang.
+	// Если для языка нет сообщения, выводится подсказка из политики.
+	catalog, err := policy.LoadCatalog("../../messages")
+	if err != nil {
+		fmt.Printf("Ошибка при загрузке каталога сообщений: %v\n", err)
+		return
+	}
+
+	// Кейсы вычисляются и сравниваются с ожидаемым результатом, а решения выводятся подробно
+	report := cases.Run(ctx, engine, testCases)
+	for i, res := range report.Results {
+		fmt.Printf(color.BlueString("Кейс: \"%s\":\n", res.Name))
+		// Входные данные, не прошедшие проверку по схеме, - ошибка клиента, а не отказ в доступе
+		if res.InputError != nil {
+			fmt.Println(color.YellowString("Некорректные входные данные"))
+			for _, fe := range res.InputError.Errors {
+				fmt.Printf("- %s: %s\n", fe.Field, fe.Message)
+			}
+			fmt.Println()
+			continue
+		}
+
+		allowed, err := policy.Decode[result](res.Decision)
 		if err != nil {
 			fmt.Printf("Ошибка при проверке доступа: %v\n", err)
+			fmt.Println()
 			continue
 		}
 
-		if allowed.accessAllowed {
+		if allowed.AccessAllowed {
 			fmt.Println(color.GreenString("Доступ разрешен"))
 		} else {
 			fmt.Println(color.RedString("Доступ запрещен"))
-			if !allowed.resourceValid {
+			if !allowed.ResourceValid {
 				fmt.Println("Ресурс не валиден")
 			}
-			if !allowed.permissionsGranted {
-				fmt.Println("Недостаточно прав доступа к ресурсу")
-				fmt.Printf("Не хватает прав: %v\n", allowed.missingPermissions)
+			if !allowed.PermissionsGranted {
+				fmt.Printf("Недостаточно прав доступа к ресурсу для действия %q\n", allowed.Action)
+				fmt.Printf("Не хватает прав: %v\n", allowed.MissingPermissions)
+			}
+			for _, violation := range allowed.Violations {
+				fmt.Printf("- %v\n  Подсказка: %s\n", violation, catalog.Message(*lang, violation))
+			}
+
+			// Дерево вычисленных правил показывает, какое выражение какого правила не выполнилось и с какими значениями
+			if *explain {
+				explanation, err := engine.Explain(ctx, testCases[i].Input)
+				if err != nil {
+					fmt.Printf("Ошибка при объяснении решения: %v\n", err)
+				} else {
+					fmt.Print(color.New(color.Faint).Sprint(explanation))
+				}
 			}
 		}
 
 		fmt.Println()
 	}
 
+	// Итог: совпал ли результат каждого кейса с ожидаемым в файле кейсов
+	report.Print(os.Stdout)
+	if report.Failed() > 0 {
+		os.Exit(1)
+	}
 }
 
-func checkAccess(ctx context.Context, testCase teatCase) (result, error) {
-	// Загружаем и компилируем объединённую политику
-	query, err := rego.New(
-		// Запрос к результату правила allow в пакете authorization.
+func newEngine() *policy.Engine {
+	opts := []policy.Option{
+		// policy.WithFiles ищет и загружает Rego-файлы по заданным путям.
+		// Это полезно для организации больших проектов, где политики хранятся в отдельных файлах.
+		policy.WithFiles("./resource_check.rego", "./permission_check.rego", "./final_check.rego"),
+		// Ресурсы не зашиты в политику, а загружаются в data.resources из файла с данными.
+		// Вместо файла можно передать собственный источник через policy.WithDataProvider.
+		policy.WithDataFiles("./data.json"),
+		// JSON Schema входных данных: при компиляции по ней проверяются типы в политиках,
+		// а перед каждой проверкой доступа - сами входные данные
+		policy.WithInputSchema("./schemas/input.json"),
+	}
+
+	// Вывод print() из политик направляется в лог вместе с идентификатором решения, модулем и строкой
+	if *printOut {
+		opts = append(opts, policy.WithPrintLogger(slog.Default()))
+	}
+
+	// Загружаем объединённую политику. Компиляция происходит один раз, при первом запросе,
+	// после чего движок переиспользует подготовленный запрос для всех кейсов.
+	return policy.New(
+		// Запрос к результату правила result в пакете final_check.
 		// data:
 		// Пространство политик по-умолчанию.
 		// Всё что описано в файле политики, доступно через data, если специально не задавать кастомное пространство.
@@ -111,77 +146,7 @@ func checkAccess(ctx context.Context, testCase teatCase) (result, error) {
 		// Пакет, в котором находится политика. Задается в поле package политики.
 		// result:
 		// Именованное правило, в результате которого лежит финальный ответ по вопросу доступа.
-		rego.Query("data.final_check.result"),
-		// В отличие от rego.Module, который принимает политику как строку,
-		// rego.Load ищет и загружает Rego-файлы по заданным путям.
-		// Это полезно для организации больших проектов, где политики хранятся в отдельных файлах.
-		// Первый аргумент:
-		// Имя файлов с политиками.
-		// Второй аргумент:
-		// Дополнительные опции загрузки, которые можно настроить, например,
-		// для включения и исключения определенных файлов. Можно передать nil, если нет особых требований.
-		rego.Load([]string{"./resource_check.rego", "./permission_check.rego", "./final_check.rego"}, nil),
-	).
-		// Метод PrepareForEval используется для предварительной подготовки
-		// запроса, чтобы его можно было повторно использовать с разными входными
-		// данными без необходимости заново загружать и компилировать политику каждый раз.
-		PrepareForEval(ctx)
-	if err != nil {
-		return result{}, fmt.Errorf("ошибка при компиляции политики: %w", err)
-	}
-
-	// Выполнение запроса
-	rs, err := query.Eval(ctx, rego.EvalInput(testCase.input))
-	if err != nil {
-		return result{}, fmt.Errorf("ошибка при оценке политики: %w", err)
-	}
-	if len(rs) == 0 {
-		return result{}, fmt.Errorf("политика не вернула результат")
-	}
-
-	res, ok := rs[0].Expressions[0].Value.(map[string]interface{})
-	if !ok {
-		return result{}, fmt.Errorf("невозможно преобразовать результат в map[string]interface{}")
-	}
-
-	return unmarshal(res)
-}
-
-func unmarshal(data map[string]interface{}) (result, error) {
-	accessAllowed, ok := data[accessAllowedKey].(bool)
-	if !ok {
-		return result{}, fmt.Errorf("невозможно преобразовать %s в bool", accessAllowedKey)
-	}
-
-	resourceValid, ok := data[resourceValidKey].(bool)
-	if !ok {
-		return result{}, fmt.Errorf("невозможно преобразовать %s в bool", resourceValidKey)
-	}
-
-	permissionsGranted, ok := data[permissionsGrantedKey].(bool)
-	if !ok {
-		return result{}, fmt.Errorf("невозможно преобразовать %s в bool", permissionsGrantedKey)
-	}
-
-	missingPermissionsRaw, ok := data[missingPermissionsKey].([]interface{})
-	if !ok {
-		return result{}, fmt.Errorf("невозможно преобразовать %s в []interface{}", missingPermissionsKey)
-	}
-
-	missingPermissions := make([]string, 0, len(missingPermissionsRaw))
-	for _, v := range missingPermissionsRaw {
-		perm, okPerm := v.(string)
-		if !okPerm {
-			return result{}, fmt.Errorf("невозможно преобразовать %s в string", missingPermissionsKey)
-		}
-
-		missingPermissions = append(missingPermissions, perm)
-	}
-
-	return result{
-		accessAllowed:      accessAllowed,
-		resourceValid:      resourceValid,
-		permissionsGranted: permissionsGranted,
-		missingPermissions: missingPermissions,
-	}, nil
+		"data.final_check.result",
+		opts...,
+	)
 }
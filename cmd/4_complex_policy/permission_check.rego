@@ -2,17 +2,88 @@ package permission_check
 
 default permissionsGranted = false
 
-# Set необходимых прав для операции
-required_permissions := {"read", "write"}
+# Действие, которое пытается выполнить пользователь: read, update, delete или любое действие из data.actions.
+# Поле читается ссылкой, а не object.get(input, ...): при частичном вычислении (policy.Engine.Filter)
+# вызов с input целиком откладывается, если неизвестно любое поле input, например поле ресурса.
+default action := ""
+
+action := input.action
+
+# Действие описано в data.actions
+actionKnown {
+    data.actions[action]
+}
+
+# Set необходимых прав для действия из data.actions.
+# Для неизвестного действия set пустой, но доступ не выдается: permissionsGranted требует actionKnown.
+required_permissions := {perm | perm := data.actions[action].required_permissions[_]}
+
+# Импликации прав из data.permissions.implies: право -> права, которые оно включает.
+# Например write включает read, а admin включает все права ("**").
+default implications = {}
+
+implications := data.permissions.implies
 
 # Преобразуем массив прав пользователя в set (set'ы можно вычитать друг из друга)
 user_permissions_set := {perm | perm := lower(input.user_permissions[_])}
 
-# Вычисление недостающих прав
-missingPermissions := required_permissions - user_permissions_set
+# Граф импликаций, в который входят и права без собственных импликаций,
+# так как graph.reachable возвращает только вершины графа
+permission_nodes := {perm | implications[perm]} | {perm | perm := implications[_][_]} | user_permissions_set
+
+implication_graph := {perm: object.get(implications, perm, []) | perm := permission_nodes[_]}
 
-# Проверка, что все требуемые права присутствуют у пользователя
+# Права пользователя вместе со всеми правами, которые они включают транзитивно
+effective_permissions := graph.reachable(implication_graph, user_permissions_set)
+
+# Право покрыто, если оно есть среди прав пользователя...
+covered(perm) {
+    effective_permissions[perm]
+}
+
+# ...или подходит под шаблон: documents:* включает documents:read,
+# documents:read:* включает documents:read:own, а ** включает любое право
+covered(perm) {
+    pattern := effective_permissions[_]
+    contains(pattern, "*")
+    glob.match(pattern, [":"], perm)
+}
+
+# Вычисление недостающих прав: только те, которые не покрыты ни правами пользователя, ни их импликациями, ни шаблонами
+missingPermissions := {perm | perm := required_permissions[_]; not covered(perm)}
+
+# Проверка, что действие известно и все требуемые для него права присутствуют у пользователя
 permissionsGranted {
+    actionKnown
     print(missingPermissions)
     count(missingPermissions) == 0
 }
+
+# Действия, описанные в data.actions
+known_actions := sort([name | data.actions[name]])
+
+# Нарушения в едином для всех проверок формате: code, field, expected, actual, params, hint
+action_violations := [
+    {
+        "code": "action_unknown",
+        "field": "action",
+        "expected": known_actions,
+        "actual": action,
+        "hint": sprintf("Action %v is not defined, expected one of %v", [action, known_actions])
+    } |
+    not actionKnown
+]
+
+permission_violations := [
+    {
+        "code": "permission_missing",
+        "field": "user_permissions",
+        "expected": perm,
+        "actual": sort(user_permissions_set),
+        "params": {"action": action},
+        "hint": sprintf("Permission %v is required for action %v", [perm, action])
+    } |
+    perm := sort(missingPermissions)[_]
+]
+
+violations := array.concat(action_violations, permission_violations)
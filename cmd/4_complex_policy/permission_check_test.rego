@@ -4,7 +4,7 @@ import data.permission_check
 
 # Тест: Проверка, что у пользователя есть все необходимые права
 test_permissions_granted {
-    input := {"user_permissions": ["read", "write"]}
+    input := {"action": "update", "user_permissions": ["read", "write"]}
 
     result := permission_check.permissionsGranted with input as input
     result  # Ожидаем, что permissionsGranted возвращает true
@@ -12,7 +12,7 @@ test_permissions_granted {
 
 # Тест: Проверка, что у пользователя нет всех необходимых прав
 test_permissions_missing {
-    input := {"user_permissions": ["read"]}
+    input := {"action": "update", "user_permissions": ["read"]}
 
     result := permission_check.permissionsGranted with input as input
     not result  # Ожидаем, что permissionsGranted возвращает false
@@ -20,8 +20,103 @@ test_permissions_missing {
 
 # Тест: Проверка списка недостающих прав
 test_missing_permissions {
-    input := {"user_permissions": ["read"]}
+    input := {"action": "update", "user_permissions": ["read"]}
 
     result := permission_check.missingPermissions with input as input
     result == {"write"}  # Ожидаем, что недостающие права включают "write"
 }
+
+# Тест: Право write включает read
+test_write_implies_read {
+    input := {"action": "update", "user_permissions": ["write"]}
+
+    permission_check.permissionsGranted with input as input
+}
+
+# Тест: Право admin включает все права
+test_admin_implies_everything {
+    input := {"action": "update", "user_permissions": ["admin"]}
+
+    permission_check.permissionsGranted with input as input
+        with permission_check.required_permissions as {"read", "write", "documents:delete", "billing:refund:full"}
+}
+
+# Тест: Импликации применяются транзитивно
+test_implications_transitive {
+    input := {"action": "update", "user_permissions": ["documents:admin"]}
+
+    result := permission_check.effective_permissions with input as input
+        with data.permissions.implies as {"documents:admin": ["documents:write"], "documents:write": ["documents:read"]}
+    result == {"documents:admin", "documents:write", "documents:read"}
+}
+
+# Тест: Шаблон documents:* покрывает права на документы, но не другие ресурсы
+test_wildcard_permissions {
+    input := {"action": "update", "user_permissions": ["documents:*"]}
+
+    result := permission_check.missingPermissions with input as input
+        with permission_check.required_permissions as {"documents:read", "documents:write", "billing:read"}
+    result == {"billing:read"}
+}
+
+# Тест: Шаблон documents:read:* покрывает только вложенные права чтения
+test_nested_wildcard_permissions {
+    input := {"action": "update", "user_permissions": ["documents:read:*"]}
+
+    result := permission_check.missingPermissions with input as input
+        with permission_check.required_permissions as {"documents:read:own", "documents:read:shared", "documents:write:own"}
+    result == {"documents:write:own"}
+}
+
+# Тест: Необходимые права берутся из data.actions для действия из input
+test_required_permissions_per_action {
+    read := permission_check.required_permissions with input as {"action": "read", "user_permissions": []}
+    read == {"read"}
+
+    update := permission_check.required_permissions with input as {"action": "update", "user_permissions": []}
+    update == {"read", "write"}
+}
+
+# Тест: Для действия delete права на чтение и запись недостаточны
+test_delete_requires_delete_permission {
+    input := {"action": "delete", "user_permissions": ["read", "write"]}
+
+    not permission_check.permissionsGranted with input as input
+    permission_check.missingPermissions == {"delete"} with input as input
+}
+
+# Тест: Пользовательское действие из данных
+test_custom_action {
+    input := {"action": "publish", "user_permissions": ["documents:*", "read"]}
+
+    permission_check.permissionsGranted with input as input
+}
+
+# Тест: Доступ не выдается для неизвестного действия и при отсутствии действия
+test_unknown_action_denied {
+    not permission_check.permissionsGranted with input as {"action": "destroy", "user_permissions": ["admin"]}
+    not permission_check.permissionsGranted with input as {"user_permissions": ["admin"]}
+}
+
+# Тест: Каждое недостающее право - отдельное нарушение
+test_violations_missing_permissions {
+    input := {"action": "delete", "user_permissions": ["read"]}
+
+    result := permission_check.violations with input as input
+    count(result) == 1
+    result[0].code == "permission_missing"
+    result[0].field == "user_permissions"
+    result[0].expected == "delete"
+    result[0].actual == ["read"]
+}
+
+# Тест: Неизвестное действие - нарушение action_unknown со списком известных действий
+test_violations_unknown_action {
+    input := {"action": "destroy", "user_permissions": ["read"]}
+
+    result := permission_check.violations with input as input
+    count(result) == 1
+    result[0].code == "action_unknown"
+    result[0].actual == "destroy"
+    result[0].expected == ["delete", "publish", "read", "update"]
+}
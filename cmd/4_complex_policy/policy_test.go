@@ -0,0 +1,12 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/olezhek28/access_policy/pkg/policy/policytest"
+)
+
+// TestPolicies выполняет тесты политик *_test.rego из директории примера: каждое правило test_* - отдельный подтест
+func TestPolicies(t *testing.T) {
+	policytest.Run(t, []string{"."})
+}
@@ -2,14 +2,64 @@ package resource_check
 
 default resourceCondition = false
 
-policy_resource := {
-	"source_uuid": "0FF8AFB4-55D2-4836-B17C-643AD59BBB2F",
-    "source_slug": "some_slug"
-}
+# Идентификатор ресурса из запроса
+requested_uuid := object.get(input, "source_uuid", "")
+
+# Ресурсы хранятся в data.resources по идентификатору, поэтому одна политика проверяет любое количество ресурсов.
+# Если ресурс с таким идентификатором не зарегистрирован, policy_resource не определен.
+policy_resource := data.resources[requested_uuid]
 
-# Ожидаем, что ресурс имеет корректный ID и имя
+# Ожидаем, что ресурс зарегистрирован и имеет корректное имя
 resourceCondition {
-	policy_resource.source_uuid == input.source_uuid
 	policy_resource.source_slug == input.source_slug
 	print("Resource check passed")
 }
+
+# Нарушения в едином для всех проверок формате: code, field, expected, actual, params, hint.
+# Поле ресурса, которого нет во входных данных, - отдельное нарушение input_missing.
+violations = [violation |
+    expected := policy_resource[key]
+    violation := field_violation(key, expected)
+] {
+    policy_resource
+}
+
+# Ресурс с таким идентификатором не зарегистрирован
+violations = [
+    {
+        "code": "resource_not_registered",
+        "field": "source_uuid",
+        "expected": "registered resource",
+        "actual": requested_uuid,
+        "hint": sprintf("Resource %v is not registered", [requested_uuid])
+    }
+] {
+    not policy_resource
+}
+
+field_violation(key, expected) = violation {
+    not has_key(input, key)
+    violation := {
+        "code": "input_missing",
+        "field": key,
+        "expected": expected,
+        "actual": null,
+        "hint": sprintf("Field %v is missing, expected %v", [key, expected])
+    }
+}
+
+field_violation(key, expected) = violation {
+    actual := input[key]
+    expected != actual
+    violation := {
+        "code": "resource_field_mismatch",
+        "field": key,
+        "expected": expected,
+        "actual": actual,
+        "hint": sprintf("Expected %v for %v, but got %v", [expected, key, actual])
+    }
+}
+
+has_key(obj, key) {
+    _ = obj[key]
+}
@@ -45,3 +45,54 @@ test_resource_invalid_uuid_and_slug {
     result := resource_check.resourceCondition with input as input
     not result  # Ожидаем, что resourceCondition возвращает false
 }
+
+# Тест: Проверка подсказки для несовпадающего slug
+test_violations_invalid_slug {
+    input := {
+        "source_uuid": "0FF8AFB4-55D2-4836-B17C-643AD59BBB2F",
+        "source_slug": "incorrect_slug"
+    }
+
+    result := resource_check.violations with input as input
+    count(result) == 1  # Ожидаем одно несоответствие
+    result[0].code == "resource_field_mismatch"
+    result[0].field == "source_slug"
+    result[0].expected == "some_slug"
+    result[0].actual == "incorrect_slug"
+}
+
+# Тест: Проверка подсказки для незарегистрированного ресурса
+test_violations_unknown_resource {
+    input := {
+        "source_uuid": "incorrect_uuid",
+        "source_slug": "some_slug"
+    }
+
+    result := resource_check.violations with input as input
+    count(result) == 1  # Ожидаем одно несоответствие
+    result[0].code == "resource_not_registered"
+    result[0].field == "source_uuid"
+    result[0].actual == "incorrect_uuid"
+}
+
+# Тест: Проверка, что ресурсы берутся из data.resources
+test_resource_valid_from_data {
+    input := {
+        "source_uuid": "uuid_from_data",
+        "source_slug": "slug_from_data"
+    }
+
+    result := resource_check.resourceCondition with input as input with data.resources as {"uuid_from_data": {"source_slug": "slug_from_data"}}
+    result  # Ожидаем, что resourceCondition возвращает true
+}
+
+# Тест: Отсутствующее во входных данных поле - отдельное нарушение
+test_violations_missing_field {
+    input := {"source_uuid": "0FF8AFB4-55D2-4836-B17C-643AD59BBB2F"}
+
+    result := resource_check.violations with input as input
+    count(result) == 1
+    result[0].code == "input_missing"
+    result[0].field == "source_slug"
+    result[0].actual == null
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	"github.com/olezhek28/access_policy/pkg/policy"
+	"github.com/olezhek28/access_policy/pkg/policy/cases"
+)
+
+// BenchmarkCheckAccessPrepared задержка проверки доступа с движком из кеша: политики генерируются
+// и компилируются один раз, а подготовленный запрос вызывается конкурентно
+func BenchmarkCheckAccessPrepared(b *testing.B) {
+	ctx := context.Background()
+	data, testCases := loadBenchData(b)
+
+	engines, err := newEngineCache()
+	if err != nil {
+		b.Fatalf("ошибка при загрузке шаблонов: %v", err)
+	}
+	if _, err = engines.Engine(ctx, data); err != nil {
+		b.Fatalf("ошибка при компиляции политик: %v", err)
+	}
+	b.ResetTimer()
+
+	var counter atomic.Int64
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			i := counter.Add(1)
+
+			engine, err := engines.Engine(ctx, data)
+			if err != nil {
+				b.Errorf("ошибка при компиляции политик: %v", err)
+				return
+			}
+
+			mustEval(ctx, b, engine, testCases[int(i)%len(testCases)].Input)
+		}
+	})
+}
+
+// BenchmarkCheckAccessRecompile задержка проверки доступа, если политики генерируются из шаблонов
+// и компилируются на каждый запрос, как работал пример до появления кеша движков
+func BenchmarkCheckAccessRecompile(b *testing.B) {
+	ctx := context.Background()
+	data, testCases := loadBenchData(b)
+	b.ResetTimer()
+
+	var counter atomic.Int64
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			i := counter.Add(1)
+
+			engines, err := newEngineCache()
+			if err != nil {
+				b.Errorf("ошибка при загрузке шаблонов: %v", err)
+				return
+			}
+
+			engine, err := engines.Engine(ctx, data)
+			if err != nil {
+				b.Errorf("ошибка при компиляции политик: %v", err)
+				return
+			}
+
+			mustEval(ctx, b, engine, testCases[int(i)%len(testCases)].Input)
+		}
+	})
+}
+
+func loadBenchData(b *testing.B) (PolicyData, []cases.Case[AccessRequest]) {
+	data, err := loadPolicyData("policy_data.json")
+	if err != nil {
+		b.Fatalf("ошибка при загрузке данных для шаблонов: %v", err)
+	}
+
+	testCases, err := cases.Load[AccessRequest]("cases.yaml")
+	if err != nil {
+		b.Fatalf("ошибка при загрузке кейсов: %v", err)
+	}
+
+	return data, testCases
+}
+
+func mustEval(ctx context.Context, b *testing.B, engine *policy.Engine, input AccessRequest) {
+	// Отклоненные по схеме входные данные - ожидаемый результат проверки, а не ошибка бенчмарка.
+	// Вызывается из горутин RunParallel, поэтому ошибка не прерывает бенчмарк через Fatal.
+	var inputErr *policy.InputError
+	if _, err := engine.Eval(ctx, input); err != nil && !errors.As(err, &inputErr) {
+		b.Errorf("ошибка при проверке доступа: %v", err)
+	}
+}
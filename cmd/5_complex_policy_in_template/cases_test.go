@@ -0,0 +1,28 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/olezhek28/access_policy/pkg/policy/policytest"
+)
+
+// TestCases выполняет кейсы из cases.yaml для политик, сгенерированных из шаблонов с данными policy_data.json
+func TestCases(t *testing.T) {
+	data, err := loadPolicyData("policy_data.json")
+	if err != nil {
+		t.Fatalf("ошибка при загрузке данных для шаблонов: %v", err)
+	}
+
+	engines, err := newEngineCache()
+	if err != nil {
+		t.Fatalf("ошибка при загрузке шаблонов: %v", err)
+	}
+
+	engine, err := engines.Engine(context.Background(), data)
+	if err != nil {
+		t.Fatalf("ошибка при компиляции политик: %v", err)
+	}
+
+	policytest.RunCases[AccessRequest](t, engine, "cases.yaml")
+}
@@ -1,172 +1,183 @@
 package main
 
 import (
-	"bytes"
 	"context"
+	"flag"
 	"fmt"
-	"text/template"
+	"log/slog"
+	"os"
+	"time"
 
-	"github.com/brianvoe/gofakeit/v6"
 	"github.com/fatih/color"
-	"github.com/open-policy-agent/opa/rego"
+	"github.com/open-policy-agent/opa/util"
+
+	"github.com/olezhek28/access_policy/pkg/policy"
+	"github.com/olezhek28/access_policy/pkg/policy/cases"
 )
 
-const (
-	accessAllowedKey      = "access_allowed"
-	resourceValidKey      = "resource_valid"
-	permissionsGrantedKey = "permissions_granted"
-	missingPermissionsKey = "missing_permissions"
+var (
+	printOut = flag.Bool("print", false, "выводить print() из политик в лог")
+	lang     = flag.String("lang", "ru", "язык сообщений о нарушениях, например ru или en")
+	// dataPath данные для шаблонов: ресурс и права для каждого действия
+	dataPath = flag.String("data", "policy_data.json", "JSON/YAML-файл с данными PolicyData для шаблонов")
+	// casesPath файл кейсов: входные данные и ожидаемый результат политики для каждого кейса
+	casesPath = flag.String("cases", "cases.yaml", "YAML/JSON-файл с кейсами проверки доступа")
 )
 
 // PolicyData Данные для подстановки в шаблоны
 type PolicyData struct {
-	SourceUUID          string
-	SourceSlug          string
-	RequiredPermissions []string
+	SourceUUID string
+	SourceSlug string
+	// Actions необходимые права для каждого действия
+	Actions map[string][]string
+	// Implies импликации прав: право -> права, которые оно включает
+	Implies map[string][]string
 }
 
-type teatCase struct {
-	name  string
-	input map[string]interface{}
-}
+// Структуры входных данных AccessRequest, ResourceRef и Subject генерируются из JSON Schema входных данных политики
+//go:generate go run github.com/olezhek28/access_policy/cmd/inputgen -schema schemas/input.json -out input_gen.go
 
+// result Итоговый результат политики final_check.result
 type result struct {
-	accessAllowed      bool
-	resourceValid      bool
-	permissionsGranted bool
-	missingPermissions []string
+	Action             string   `rego:"action"`
+	AccessAllowed      bool     `rego:"access_allowed"`
+	ResourceValid      bool     `rego:"resource_valid"`
+	PermissionsGranted bool     `rego:"permissions_granted"`
+	MissingPermissions []string `rego:"missing_permissions"`
+	// Violations нарушения всех проверок в едином формате
+	Violations []policy.Violation `rego:"violations"`
 }
 
 func main() {
+	flag.Parse()
+
 	ctx := context.Background()
 
-	var (
-		sourceUUID = gofakeit.UUID()
-		sourceSlug = gofakeit.Word()
-	)
+	// Данные для шаблонов фиксированы в файле, чтобы ожидаемые результаты кейсов не зависели от запуска
+	data, err := loadPolicyData(*dataPath)
+	if err != nil {
+		fmt.Printf("Ошибка при загрузке данных для шаблонов: %v\n", err)
+		return
+	}
 
-	data := PolicyData{
-		SourceUUID:          sourceUUID,
-		SourceSlug:          sourceSlug,
-		RequiredPermissions: []string{"create", "read", "update", "delete"},
+	// Кейсы проверки доступа: входные данные и ожидаемый результат политики
+	testCases, err := cases.Load[AccessRequest](*casesPath)
+	if err != nil {
+		fmt.Printf("Ошибка при загрузке кейсов: %v\n", err)
+		return
+	}
+
+	engines, err := newEngineCache()
+	if err != nil {
+		fmt.Printf("Ошибка при загрузке шаблонов: %v\n", err)
+		return
 	}
 
-	policies, err := generatePolicies(data)
+	// Сообщения о нарушениях берутся из каталога по коду нарушения на языке из флага -lang.
+	// Если для языка нет сообщения, выводится подсказка из политики.
+	catalog, err := policy.LoadCatalog("../../messages")
 	if err != nil {
-		fmt.Printf("Ошибка при генерации политик: %v\n", err)
+		fmt.Printf("Ошибка при загрузке каталога сообщений: %v\n", err)
 		return
 	}
 
-	// Входные данные для проверки
-	inputData := []teatCase{
-		{
-			name: "Доступ разрешен, все параметры валидны",
-			input: map[string]interface{}{
-				"source_uuid":      sourceUUID,
-				"source_slug":      sourceSlug,
-				"user_permissions": []string{"create", "read", "update", "delete"},
-			},
-		},
-		{
-			name: "Доступ разрешен, все параметры валидны, но права не в том регистре",
-			input: map[string]interface{}{
-				"source_uuid":      sourceUUID,
-				"source_slug":      sourceSlug,
-				"user_permissions": []string{"cReate", "Read", "updAte", "Delete"},
-			},
-		},
-		{
-			name: "Доступ запрещен, идентификатор ресурса не валиден",
-			input: map[string]interface{}{
-				"source_uuid":      "invalid_uuid",
-				"source_slug":      sourceSlug,
-				"user_permissions": []string{"create", "read", "update", "delete"},
-			},
-		},
-		{
-			name: "Доступ запрещен, slug ресурса не валиден",
-			input: map[string]interface{}{
-				"source_uuid":      sourceUUID,
-				"source_slug":      "invalid_slug",
-				"user_permissions": []string{"create", "read", "update", "delete"},
-			},
-		},
-		{
-			name: "Доступ запрещен, недостаточно прав доступа к ресурсу",
-			input: map[string]interface{}{
-				"source_uuid":      sourceUUID,
-				"source_slug":      sourceSlug,
-				"user_permissions": []string{"read"},
-			},
-		},
+	// Политики генерируются и компилируются один раз, повторные запросы получают движок из кеша
+	engine, err := engines.Engine(ctx, data)
+	if err != nil {
+		fmt.Printf("Ошибка при компиляции политик: %v\n", err)
+		return
 	}
 
-	for _, input := range inputData {
-		fmt.Printf(color.BlueString("Кейс: \"%s\":\n", input.name))
-		allowed, err := checkAccess(ctx, policies, input)
+	// Кейсы вычисляются и сравниваются с ожидаемым результатом, а решения выводятся подробно
+	report := cases.Run(ctx, engine, testCases)
+	for _, res := range report.Results {
+		fmt.Printf(color.BlueString("Кейс: \"%s\":\n", res.Name))
+		// Входные данные, не прошедшие проверку по схеме, - ошибка клиента, а не отказ в доступе
+		if res.InputError != nil {
+			fmt.Println(color.YellowString("Некорректные входные данные"))
+			for _, fe := range res.InputError.Errors {
+				fmt.Printf("- %s: %s\n", fe.Field, fe.Message)
+			}
+			fmt.Println()
+			continue
+		}
+
+		allowed, err := policy.Decode[result](res.Decision)
 		if err != nil {
 			fmt.Printf("Ошибка при проверке доступа: %v\n", err)
+			fmt.Println()
 			continue
 		}
 
-		if allowed.accessAllowed {
+		if allowed.AccessAllowed {
 			fmt.Println(color.GreenString("Доступ разрешен"))
 		} else {
 			fmt.Println(color.RedString("Доступ запрещен"))
-			if !allowed.resourceValid {
+			if !allowed.ResourceValid {
 				fmt.Println("Ресурс не валиден")
 			}
-			if !allowed.permissionsGranted {
-				fmt.Println("Недостаточно прав доступа к ресурсу")
-				fmt.Printf("Не хватает прав: %v\n", allowed.missingPermissions)
+			if !allowed.PermissionsGranted {
+				fmt.Printf("Недостаточно прав доступа к ресурсу для действия %q\n", allowed.Action)
+				fmt.Printf("Не хватает прав: %v\n", allowed.MissingPermissions)
+			}
+			for _, violation := range allowed.Violations {
+				fmt.Printf("- %v\n  Подсказка: %s\n", violation, catalog.Message(*lang, violation))
 			}
 		}
 
 		fmt.Println()
 	}
-}
 
-func generatePolicies(data PolicyData) ([]string, error) {
-	// Генерация каждого файла
-	finalCheckPolicy, err := generatePolicy("final_check_policy.tmpl", data)
-	if err != nil {
-		return nil, fmt.Errorf("ошибка генерации шаблона final_check_policy: %w", err)
+	// Итог: совпал ли результат каждого кейса с ожидаемым в файле кейсов
+	report.Print(os.Stdout)
+	fmt.Println()
+
+	stats := engines.Stats()
+	fmt.Printf("Кеш политик: движков %d, попаданий %d, промахов %d, компиляций %d, вытеснено %d\n",
+		stats.Size, stats.Hits, stats.Misses, stats.Compilations, stats.Evictions+stats.Expirations)
+
+	if report.Failed() > 0 {
+		os.Exit(1)
 	}
+}
 
-	permissionCheckPolicy, err := generatePolicy("permission_check_policy.tmpl", data)
+// loadPolicyData загружает данные для шаблонов из JSON/YAML-файла
+func loadPolicyData(path string) (PolicyData, error) {
+	raw, err := os.ReadFile(path)
 	if err != nil {
-		return nil, fmt.Errorf("ошибка генерации шаблона permission_check_policy: %w", err)
+		return PolicyData{}, err
 	}
 
-	resourceCheckPolicy, err := generatePolicy("resource_check_policy.tmpl", data)
-	if err != nil {
-		return nil, fmt.Errorf("ошибка генерации шаблона resource_check_policy: %w", err)
+	var data PolicyData
+	if err = util.Unmarshal(raw, &data); err != nil {
+		return PolicyData{}, fmt.Errorf("ошибка разбора %s: %w", path, err)
 	}
 
-	return []string{finalCheckPolicy, permissionCheckPolicy, resourceCheckPolicy}, nil
+	return data, nil
 }
 
-func generatePolicy(templatePath string, data PolicyData) (string, error) {
-	// Загружаем шаблон из файла
-	tmpl, err := template.ParseFiles(templatePath)
+func newEngineCache() (*policy.EngineCache, error) {
+	// Все шаблоны *.tmpl из текущей директории. Новый шаблон подхватывается без изменения кода.
+	// В строгом режиме значения можно подставлять только через regoString, regoSet и regoNumber.
+	templates, err := policy.LoadTemplates(".", policy.StrictTemplates())
 	if err != nil {
-		return "", fmt.Errorf("ошибка загрузки шаблона: %w", err)
+		return nil, err
 	}
 
-	// Применяем шаблон к данным
-	var output bytes.Buffer
-	err = tmpl.Execute(&output, data)
-	if err != nil {
-		return "", fmt.Errorf("ошибка выполнения шаблона: %w", err)
+	engineOpts := []policy.Option{
+		// Схема входных данных общая для политик всех ресурсов
+		policy.WithInputSchema("./schemas/input.json"),
 	}
 
-	return output.String(), nil
-}
+	// Вывод print() из шаблонов политик направляется в лог вместе с идентификатором решения, модулем и строкой
+	if *printOut {
+		engineOpts = append(engineOpts, policy.WithPrintLogger(slog.Default()))
+	}
 
-func checkAccess(ctx context.Context, policies []string, testCase teatCase) (result, error) {
-	// Загружаем и компилируем объединённую политику
-	query, err := rego.New(
-		// Запрос к результату правила allow в пакете authorization.
+	// Для каждого ресурса (набора PolicyData) политики генерируются из шаблонов и компилируются один раз,
+	// после чего движок переиспользуется для всех проверок доступа к этому ресурсу.
+	return policy.NewEngineCache(templates,
+		// Запрос к результату правила result в пакете final_check.
 		// data:
 		// Пространство политик по-умолчанию.
 		// Всё что описано в файле политики, доступно через data, если специально не задавать кастомное пространство.
@@ -174,71 +185,9 @@ func checkAccess(ctx context.Context, policies []string, testCase teatCase) (res
 		// Пакет, в котором находится политика. Задается в поле package политики.
 		// result:
 		// Именованное правило, в результате которого лежит финальный ответ по вопросу доступа.
-		rego.Query("data.final_check.result"),
-		rego.Module("final_check_policy.rego", policies[0]),
-		rego.Module("permission_check_policy.rego", policies[1]),
-		rego.Module("resource_check_policy.rego", policies[2]),
-	).
-		// Метод PrepareForEval используется для предварительной подготовки
-		// запроса, чтобы его можно было повторно использовать с разными входными
-		// данными без необходимости заново загружать и компилировать политику каждый раз.
-		PrepareForEval(ctx)
-	if err != nil {
-		return result{}, fmt.Errorf("ошибка при компиляции политики: %w", err)
-	}
-
-	// Выполнение запроса
-	rs, err := query.Eval(ctx, rego.EvalInput(testCase.input))
-	if err != nil {
-		return result{}, fmt.Errorf("ошибка при оценке политики: %w", err)
-	}
-	if len(rs) == 0 {
-		return result{}, fmt.Errorf("политика не вернула результат")
-	}
-
-	res, ok := rs[0].Expressions[0].Value.(map[string]interface{})
-	if !ok {
-		return result{}, fmt.Errorf("невозможно преобразовать результат в map[string]interface{}")
-	}
-
-	return unmarshal(res)
-}
-
-func unmarshal(data map[string]interface{}) (result, error) {
-	accessAllowed, ok := data[accessAllowedKey].(bool)
-	if !ok {
-		return result{}, fmt.Errorf("невозможно преобразовать %s в bool", accessAllowedKey)
-	}
-
-	resourceValid, ok := data[resourceValidKey].(bool)
-	if !ok {
-		return result{}, fmt.Errorf("невозможно преобразовать %s в bool", resourceValidKey)
-	}
-
-	permissionsGranted, ok := data[permissionsGrantedKey].(bool)
-	if !ok {
-		return result{}, fmt.Errorf("невозможно преобразовать %s в bool", permissionsGrantedKey)
-	}
-
-	missingPermissionsRaw, ok := data[missingPermissionsKey].([]interface{})
-	if !ok {
-		return result{}, fmt.Errorf("невозможно преобразовать %s в []interface{}", missingPermissionsKey)
-	}
-
-	missingPermissions := make([]string, 0, len(missingPermissionsRaw))
-	for _, v := range missingPermissionsRaw {
-		perm, okPerm := v.(string)
-		if !okPerm {
-			return result{}, fmt.Errorf("невозможно преобразовать %s в string", missingPermissionsKey)
-		}
-
-		missingPermissions = append(missingPermissions, perm)
-	}
-
-	return result{
-		accessAllowed:      accessAllowed,
-		resourceValid:      resourceValid,
-		permissionsGranted: permissionsGranted,
-		missingPermissions: missingPermissions,
-	}, nil
+		"data.final_check.result",
+		policy.WithCacheSize(1000),
+		policy.WithCacheTTL(time.Hour),
+		policy.WithEngineOptions(engineOpts...),
+	), nil
 }
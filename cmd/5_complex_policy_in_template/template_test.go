@@ -0,0 +1,242 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/open-policy-agent/opa/ast"
+
+	"github.com/olezhek28/access_policy/pkg/policy"
+)
+
+// hostileFragments фрагменты, которыми можно было бы переписать политику, если бы значения
+// подставлялись в шаблон как есть: закрыть строку или объект, добавить правило, закомментировать остаток
+var hostileFragments = []string{
+	`"`,
+	`\`,
+	`\"`,
+	`}`,
+	`{`,
+	"\n",
+	"\t",
+	"# ",
+	`"}` + "\nresourceCondition = true\n#",
+	`", "source_slug": input.source_slug}` + "\n#",
+	`"} {"`,
+	"{{ .SourceSlug }}",
+	" ",
+	"\x00",
+	"ё😀",
+}
+
+// baselineData безобидные данные, с которыми сравнивается структура политик
+var baselineData = PolicyData{
+	SourceUUID: "uuid",
+	SourceSlug: "slug",
+	Actions: map[string][]string{
+		"read1":   {"read"},
+		"update2": {"read", "update"},
+	},
+	Implies: map[string][]string{
+		"write1": {"read"},
+	},
+}
+
+// TestHostileData подставляет каждый опасный фрагмент во все поля данных шаблонов и проверяет,
+// что структура политик не меняется, а значения попадают в политику без изменений
+func TestHostileData(t *testing.T) {
+	templates, want := loadBaseline(t)
+
+	for _, fragment := range hostileFragments {
+		t.Run(strings.ToValidUTF8(fragment, "?"), func(t *testing.T) {
+			value := "a" + fragment + "b"
+			checkTemplates(t, templates, want, hostileData(value, value, value, value))
+		})
+	}
+}
+
+// FuzzTemplates проверяет то же на случайных значениях, начиная с опасных фрагментов:
+//
+//	go test -fuzz FuzzTemplates
+func FuzzTemplates(f *testing.F) {
+	for _, fragment := range hostileFragments {
+		f.Add(fragment, fragment, fragment, fragment)
+	}
+
+	templates, want := loadBaseline(f)
+
+	f.Fuzz(func(t *testing.T, uuid, slug, action, permission string) {
+		data := hostileData(uuid, slug, action, permission)
+
+		// Строки с некорректным UTF-8 нельзя подставить без изменений, поэтому regoString их отклоняет
+		for _, s := range []string{uuid, slug, action, permission} {
+			if !utf8.ValidString(s) {
+				if _, err := templates.Render(data); err == nil {
+					t.Fatalf("%#v: некорректный UTF-8 подставлен в политику", data)
+				}
+				return
+			}
+		}
+
+		checkTemplates(t, templates, want, data)
+	})
+}
+
+// hostileData данные шаблонов с заданными значениями. Номер в конце имени действия гарантирует,
+// что действий столько же, сколько в базовых данных.
+func hostileData(uuid, slug, action, permission string) PolicyData {
+	return PolicyData{
+		SourceUUID: uuid,
+		SourceSlug: slug,
+		Actions: map[string][]string{
+			action + "1": {permission},
+			action + "2": {permission, permission + "2"},
+		},
+		Implies: map[string][]string{
+			permission + "1": {permission},
+		},
+	}
+}
+
+func loadBaseline(tb testing.TB) (*policy.TemplateRegistry, string) {
+	tb.Helper()
+
+	templates, err := policy.LoadTemplates(".", policy.StrictTemplates())
+	if err != nil {
+		tb.Fatalf("ошибка при загрузке шаблонов: %v", err)
+	}
+
+	modules, err := templates.Render(baselineData)
+	if err != nil {
+		tb.Fatalf("ошибка при генерации политик: %v", err)
+	}
+
+	want, err := policyShape(modules)
+	if err != nil {
+		tb.Fatalf("ошибка при разборе политик: %v", err)
+	}
+
+	return templates, want
+}
+
+func checkTemplates(t *testing.T, templates *policy.TemplateRegistry, want string, data PolicyData) {
+	t.Helper()
+
+	modules, err := templates.Render(data)
+	if err != nil {
+		t.Fatalf("%#v: %v", data, err)
+	}
+
+	got, err := policyShape(modules)
+	if err != nil {
+		t.Fatalf("%#v: %v", data, err)
+	}
+	if got != want {
+		t.Fatalf("%#v: структура политики изменилась:\n%s", data, got)
+	}
+
+	checkRenderedValues(t, modules, data)
+}
+
+// policyShape разбирает модули и возвращает их текст, в котором все строковые литералы заменены пустыми.
+// Совпадение формы означает, что данные изменили только значения литералов, но не структуру политики.
+func policyShape(modules []policy.Module) (string, error) {
+	shapes := make([]string, 0, len(modules))
+	for _, module := range modules {
+		m, err := ast.ParseModule(module.Name, module.Source)
+		if err != nil {
+			return "", err
+		}
+
+		normalized, err := ast.Transform(blankStrings{}, m)
+		if err != nil {
+			return "", err
+		}
+
+		shapes = append(shapes, module.Name+":\n"+normalized.(*ast.Module).String())
+	}
+
+	return strings.Join(shapes, "\n"), nil
+}
+
+// blankStrings заменяет значения строковых литералов пустыми строками
+type blankStrings struct{}
+
+func (blankStrings) Transform(x interface{}) (interface{}, error) {
+	if _, ok := x.(ast.String); ok {
+		return ast.String(""), nil
+	}
+
+	return x, nil
+}
+
+// checkRenderedValues вычисляет литералы сгенерированных политик и сравнивает их с исходными данными
+func checkRenderedValues(t *testing.T, modules []policy.Module, data PolicyData) {
+	t.Helper()
+
+	ctx := context.Background()
+
+	resource, err := policy.New("data.resource_check.policy_resource", policy.WithModules(modules...)).Eval(ctx, nil)
+	if err != nil {
+		t.Fatalf("%#v: %v", data, err)
+	}
+
+	wantResource := map[string]interface{}{
+		"source_uuid": data.SourceUUID,
+		"source_slug": data.SourceSlug,
+	}
+	if !reflect.DeepEqual(resource.Value, wantResource) {
+		t.Fatalf("ресурс в политике %q не совпадает с данными %q", resource.Value, wantResource)
+	}
+
+	checkRenderedSets(t, modules, data, "data.permission_check.required_permissions_by_action", data.Actions)
+	checkRenderedSets(t, modules, data, "data.permission_check.implications", data.Implies)
+}
+
+// checkRenderedSets вычисляет объект со значениями-множествами по запросу query и сравнивает его с want
+func checkRenderedSets(t *testing.T, modules []policy.Module, data PolicyData, query string, sets map[string][]string) {
+	t.Helper()
+
+	decision, err := policy.New(query, policy.WithModules(modules...)).Eval(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("%#v: %v", data, err)
+	}
+
+	got, err := policy.Decode[map[string][]string](decision)
+	if err != nil {
+		t.Fatalf("%#v: %v", data, err)
+	}
+
+	for key, values := range got {
+		sort.Strings(values)
+		got[key] = values
+	}
+
+	want := make(map[string][]string, len(sets))
+	for key, values := range sets {
+		want[key] = uniqueSorted(values)
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("%s в политике %q не совпадает с данными %q", query, got, want)
+	}
+}
+
+func uniqueSorted(values []string) []string {
+	set := make(map[string]struct{}, len(values))
+	for _, v := range values {
+		set[v] = struct{}{}
+	}
+
+	res := make([]string, 0, len(set))
+	for v := range set {
+		res = append(res, v)
+	}
+	sort.Strings(res)
+
+	return res
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/olezhek28/access_policy/pkg/policy"
+	_ "modernc.org/sqlite"
+)
+
+// resource строка таблицы ресурсов. Пустые source_uuid и source_slug - NULL.
+type resource struct {
+	ID         int64
+	Title      string
+	SourceUUID sql.NullString
+	SourceSlug sql.NullString
+}
+
+// fields поля ресурса в том виде, в каком они передаются в политику и в Filter.Match
+func (r resource) fields() map[string]interface{} {
+	fields := make(map[string]interface{})
+	if r.SourceUUID.Valid {
+		fields["source_uuid"] = r.SourceUUID.String
+	}
+	if r.SourceSlug.Valid {
+		fields["source_slug"] = r.SourceSlug.String
+	}
+
+	return fields
+}
+
+// user пользователь, для которого выбираются доступные ресурсы
+type user struct {
+	name        string
+	action      string
+	permissions []string
+}
+
+func main() {
+	ctx := context.Background()
+
+	// Политики и данные из cmd/4_complex_policy. Запрос - булево правило доступа, а не итоговый объект result:
+	// частичное вычисление переводит в условие именно правило, которое выполняется или нет.
+	engine := policy.New("data.final_check.accessAllowed",
+		policy.WithFiles("../4_complex_policy"),
+		policy.WithDataFiles("../4_complex_policy/data.json"),
+	)
+
+	db, err := openDB(ctx)
+	if err != nil {
+		fmt.Printf("Ошибка при подготовке базы: %v\n", err)
+		os.Exit(1)
+	}
+	defer db.Close()
+
+	users := []user{
+		{name: "Читатель", action: "read", permissions: []string{"read"}},
+		{name: "Редактор без права write", action: "update", permissions: []string{"read"}},
+		{name: "Администратор", action: "delete", permissions: []string{"admin"}},
+		{name: "Неизвестное действие", action: "archive", permissions: []string{"admin"}},
+	}
+
+	failed := false
+	for _, u := range users {
+		fmt.Printf(color.BlueString("Пользователь \"%s\": действие %s, права %v\n", u.name, u.action, u.permissions))
+
+		ok, err := listResources(ctx, engine, db, u)
+		if err != nil {
+			fmt.Printf("Ошибка: %v\n", err)
+			failed = true
+		} else if !ok {
+			failed = true
+		}
+
+		fmt.Println()
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// listResources выбирает из таблицы ресурсы, доступные пользователю, одним запросом с WHERE из политики
+// и сверяет результат с Filter.Match и с проверкой доступа к каждой строке по отдельности
+func listResources(ctx context.Context, engine *policy.Engine, db *sql.DB, u user) (bool, error) {
+	// Пользователь и действие известны, а поля ресурса - нет: их значения лежат в таблице
+	input := map[string]interface{}{
+		"action":           u.action,
+		"user_permissions": u.permissions,
+	}
+
+	filter, err := engine.Filter(ctx, input, "input.source_uuid", "input.source_slug")
+	if err != nil {
+		return false, err
+	}
+
+	where, args := filter.SQL()
+	fmt.Printf("WHERE %s\n", where)
+	fmt.Printf("аргументы: %q\n", args)
+
+	bySQL, err := queryResources(ctx, db, "SELECT id, title, source_uuid, source_slug FROM resources WHERE "+where+" ORDER BY id", args...)
+	if err != nil {
+		return false, err
+	}
+
+	if len(bySQL) == 0 {
+		fmt.Println(color.RedString("Доступных ресурсов нет"))
+	}
+	for _, r := range bySQL {
+		fmt.Println(color.GreenString("- %d. %s", r.ID, r.Title))
+	}
+
+	// Проверка: те же ресурсы должны получиться фильтром в Go и вычислением политики для каждой строки
+	all, err := queryResources(ctx, db, "SELECT id, title, source_uuid, source_slug FROM resources ORDER BY id")
+	if err != nil {
+		return false, err
+	}
+
+	var byMatch, byEval []int64
+	for _, r := range all {
+		if filter.Match(r.fields()) {
+			byMatch = append(byMatch, r.ID)
+		}
+
+		rowInput := r.fields()
+		for key, value := range input {
+			rowInput[key] = value
+		}
+
+		allowed, err := policy.Decode[bool](mustEval(ctx, engine, rowInput))
+		if err != nil {
+			return false, err
+		}
+		if allowed {
+			byEval = append(byEval, r.ID)
+		}
+	}
+
+	sqlIDs := ids(bySQL)
+	if equalIDs(sqlIDs, byMatch) && equalIDs(sqlIDs, byEval) {
+		fmt.Println(color.New(color.Faint).Sprint("SQL, Filter.Match и проверка каждой строки совпадают"))
+		return true, nil
+	}
+
+	fmt.Println(color.RedString("Результаты расходятся: SQL %v, Filter.Match %v, проверка каждой строки %v", sqlIDs, byMatch, byEval))
+	return false, nil
+}
+
+func mustEval(ctx context.Context, engine *policy.Engine, input map[string]interface{}) policy.Decision {
+	decision, err := engine.Eval(ctx, input)
+	if err != nil {
+		fmt.Printf("Ошибка при проверке доступа: %v\n", err)
+		os.Exit(1)
+	}
+
+	return decision
+}
+
+// openDB создает таблицу ресурсов в SQLite в памяти: зарегистрированные ресурсы, ресурс с чужим slug,
+// незарегистрированный ресурс и ресурсы с незаполненными полями
+func openDB(ctx context.Context) (*sql.DB, error) {
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		return nil, err
+	}
+	// У каждого соединения своя база в памяти, поэтому соединение одно
+	db.SetMaxOpenConns(1)
+
+	statements := []string{
+		`CREATE TABLE resources (
+			id INTEGER PRIMARY KEY,
+			title TEXT NOT NULL,
+			source_uuid TEXT,
+			source_slug TEXT
+		)`,
+		`INSERT INTO resources (id, title, source_uuid, source_slug) VALUES
+			(1, 'Договор поставки', '0FF8AFB4-55D2-4836-B17C-643AD59BBB2F', 'some_slug'),
+			(2, 'Отчет за квартал', '5B1D3C6E-8E1A-4C1F-9A57-2F0C8A2E7D41', 'another_slug'),
+			(3, 'Черновик со старым slug', '0FF8AFB4-55D2-4836-B17C-643AD59BBB2F', 'old_slug'),
+			(4, 'Незарегистрированный документ', '11111111-2222-3333-4444-555555555555', 'some_slug'),
+			(5, 'Документ без slug', '5B1D3C6E-8E1A-4C1F-9A57-2F0C8A2E7D41', NULL),
+			(6, 'Документ без идентификатора', NULL, 'some_slug')`,
+	}
+
+	for _, stmt := range statements {
+		if _, err = db.ExecContext(ctx, stmt); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+
+	return db, nil
+}
+
+func queryResources(ctx context.Context, db *sql.DB, query string, args ...interface{}) ([]resource, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка запроса %q: %w", strings.Join(strings.Fields(query), " "), err)
+	}
+	defer rows.Close()
+
+	var resources []resource
+	for rows.Next() {
+		var r resource
+		if err = rows.Scan(&r.ID, &r.Title, &r.SourceUUID, &r.SourceSlug); err != nil {
+			return nil, err
+		}
+		resources = append(resources, r)
+	}
+
+	return resources, rows.Err()
+}
+
+func ids(resources []resource) []int64 {
+	result := make([]int64, 0, len(resources))
+	for _, r := range resources {
+		result = append(result, r.ID)
+	}
+
+	return result
+}
+
+func equalIDs(a, b []int64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	sort.Slice(a, func(i, j int) bool { return a[i] < a[j] })
+	sort.Slice(b, func(i, j int) bool { return b[i] < b[j] })
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,311 @@
+// inputgen генерирует Go-структуры входных данных политики из ее JSON Schema,
+// чтобы имена полей в Go и в rego (input.source_uuid и т.д.) не расходились.
+//
+// Использование в пакете с политикой:
+//
+//	//go:generate go run github.com/olezhek28/access_policy/cmd/inputgen -schema schemas/input.json -out input_gen.go
+//
+// Кроме стандартных ключевых слов схемы генератор понимает два собственных (OPA и валидатор их игнорируют):
+// x-go-type - имя типа для объекта (для корня схемы по-умолчанию AccessRequest),
+// x-go-embed - имя структуры, в которую попадает свойство. Такие структуры встраиваются в родительскую,
+// поэтому при сериализации их поля остаются на верхнем уровне input, например ResourceRef с source_uuid и source_slug.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/open-policy-agent/opa/util"
+)
+
+var (
+	schemaPath = flag.String("schema", "schemas/input.json", "путь к JSON Schema входных данных (JSON или YAML)")
+	out        = flag.String("out", "input_gen.go", "файл, в который записываются структуры")
+	pkg        = flag.String("package", os.Getenv("GOPACKAGE"), "имя пакета, по-умолчанию пакет, для которого вызван go generate")
+	typeName   = flag.String("type", "AccessRequest", "имя корневой структуры, если в схеме нет x-go-type")
+)
+
+// schema ключевые слова JSON Schema, которые нужны для генерации
+type schema struct {
+	Title       string             `json:"title"`
+	Description string             `json:"description"`
+	Type        interface{}        `json:"type"`
+	Properties  map[string]*schema `json:"properties"`
+	Items       *schema            `json:"items"`
+	Required    []string           `json:"required"`
+	GoType      string             `json:"x-go-type"`
+	GoEmbed     string             `json:"x-go-embed"`
+}
+
+// structDef сгенерированная структура
+type structDef struct {
+	name   string
+	doc    string
+	embeds []string
+	fields []fieldDef
+	// props свойства схемы, попавшие в структуру, для комментария к встраиваемым структурам
+	props []string
+}
+
+type fieldDef struct {
+	name   string
+	goType string
+	tag    string
+	doc    string
+}
+
+// generator собирает структуры в порядке их появления
+type generator struct {
+	structs map[string]*structDef
+	order   []string
+}
+
+func main() {
+	flag.Parse()
+
+	if *pkg == "" {
+		log.Fatal("не задано имя пакета: запустите через go generate или передайте -package")
+	}
+
+	raw, err := os.ReadFile(*schemaPath)
+	if err != nil {
+		log.Fatalf("ошибка чтения схемы: %v", err)
+	}
+
+	src, err := generate(raw, *pkg, *schemaPath, *typeName)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err = os.WriteFile(*out, src, 0o644); err != nil {
+		log.Fatalf("ошибка записи %s: %v", *out, err)
+	}
+}
+
+// generate возвращает исходный код структур для схемы raw. schemaPath попадает в заголовок файла,
+// а defaultType - имя корневой структуры, если в схеме нет x-go-type.
+func generate(raw []byte, pkg, schemaPath, defaultType string) ([]byte, error) {
+	var root schema
+	if err := util.Unmarshal(raw, &root); err != nil {
+		return nil, fmt.Errorf("ошибка разбора схемы %s: %w", schemaPath, err)
+	}
+
+	name := root.GoType
+	if name == "" {
+		name = defaultType
+	}
+
+	g := &generator{structs: make(map[string]*structDef)}
+	if err := g.object(name, root.Title, &root); err != nil {
+		return nil, fmt.Errorf("ошибка генерации структур из %s: %w", schemaPath, err)
+	}
+
+	src, err := format.Source(g.source(pkg, schemaPath))
+	if err != nil {
+		return nil, fmt.Errorf("ошибка форматирования сгенерированного кода: %w", err)
+	}
+
+	return src, nil
+}
+
+// object добавляет структуру для объекта схемы. Свойства с x-go-embed попадают во встраиваемые структуры.
+func (g *generator) object(name, doc string, s *schema) error {
+	def, err := g.define(name, doc)
+	if err != nil {
+		return err
+	}
+
+	required := make(map[string]bool, len(s.Required))
+	for _, r := range s.Required {
+		required[r] = true
+	}
+
+	props := make([]string, 0, len(s.Properties))
+	for prop := range s.Properties {
+		props = append(props, prop)
+	}
+	sort.Strings(props)
+
+	for _, prop := range props {
+		ps := s.Properties[prop]
+
+		goType, err := g.goType(goName(prop), ps)
+		if err != nil {
+			return fmt.Errorf("%s: %w", prop, err)
+		}
+
+		tag := prop
+		if !required[prop] {
+			tag += ",omitempty"
+		}
+
+		field := fieldDef{
+			name:   goName(prop),
+			goType: goType,
+			tag:    tag,
+			doc:    ps.Description,
+		}
+
+		target := def
+		if ps.GoEmbed != "" {
+			if target, err = g.embedded(def, ps.GoEmbed); err != nil {
+				return fmt.Errorf("%s: %w", prop, err)
+			}
+		}
+
+		target.fields = append(target.fields, field)
+		target.props = append(target.props, prop)
+	}
+
+	return nil
+}
+
+// define регистрирует новую структуру
+func (g *generator) define(name, doc string) (*structDef, error) {
+	if _, ok := g.structs[name]; ok {
+		return nil, fmt.Errorf("тип %s объявлен дважды", name)
+	}
+
+	def := &structDef{name: name, doc: doc}
+	g.structs[name] = def
+	g.order = append(g.order, name)
+
+	return def, nil
+}
+
+// embedded возвращает встраиваемую в parent структуру name, создавая ее при первом обращении
+func (g *generator) embedded(parent *structDef, name string) (*structDef, error) {
+	for _, embed := range parent.embeds {
+		if embed == name {
+			return g.structs[name], nil
+		}
+	}
+
+	def, err := g.define(name, "")
+	if err != nil {
+		return nil, err
+	}
+	parent.embeds = append(parent.embeds, name)
+
+	return def, nil
+}
+
+// goType тип поля для свойства схемы. Для объекта со свойствами генерируется отдельная структура.
+func (g *generator) goType(name string, s *schema) (string, error) {
+	switch s.Type {
+	case "string":
+		return "string", nil
+	case "integer":
+		return "int", nil
+	case "number":
+		return "float64", nil
+	case "boolean":
+		return "bool", nil
+	case "array":
+		if s.Items == nil {
+			return "[]interface{}", nil
+		}
+
+		item, err := g.goType(name+"Item", s.Items)
+		if err != nil {
+			return "", err
+		}
+
+		return "[]" + item, nil
+	case "object":
+		if len(s.Properties) == 0 {
+			return "map[string]interface{}", nil
+		}
+
+		if s.GoType != "" {
+			name = s.GoType
+		}
+		if err := g.object(name, s.Description, s); err != nil {
+			return "", err
+		}
+
+		return name, nil
+	default:
+		// Свойство без типа или с несколькими типами
+		return "interface{}", nil
+	}
+}
+
+func (g *generator) source(pkg, schemaPath string) []byte {
+	var b bytes.Buffer
+
+	fmt.Fprintf(&b, "// Code generated by inputgen from %s. DO NOT EDIT.\n\n", schemaPath)
+	fmt.Fprintf(&b, "package %s\n", pkg)
+
+	for _, name := range g.order {
+		def := g.structs[name]
+
+		doc := lowerFirst(def.doc)
+		if doc == "" {
+			doc = "поля входных данных " + strings.Join(def.props, ", ")
+		}
+
+		fmt.Fprintf(&b, "\n// %s %s\ntype %s struct {\n", def.name, doc, def.name)
+		for _, embed := range def.embeds {
+			fmt.Fprintf(&b, "%s\n", embed)
+		}
+		if len(def.embeds) > 0 && len(def.fields) > 0 {
+			b.WriteString("\n")
+		}
+		for _, field := range def.fields {
+			if field.doc != "" {
+				fmt.Fprintf(&b, "// %s %s\n", field.name, lowerFirst(field.doc))
+			}
+			fmt.Fprintf(&b, "%s %s `json:%q`\n", field.name, field.goType, field.tag)
+		}
+		b.WriteString("}\n")
+	}
+
+	return b.Bytes()
+}
+
+// initialisms части имен, которые в Go пишутся заглавными буквами
+var initialisms = map[string]string{
+	"id":   "ID",
+	"uuid": "UUID",
+	"url":  "URL",
+	"uri":  "URI",
+	"api":  "API",
+	"json": "JSON",
+	"http": "HTTP",
+}
+
+// goName имя поля Go для свойства схемы: source_uuid -> SourceUUID
+func goName(prop string) string {
+	var b strings.Builder
+	for _, part := range strings.FieldsFunc(prop, func(r rune) bool { return r == '_' || r == '-' || r == '.' }) {
+		if initialism, ok := initialisms[strings.ToLower(part)]; ok {
+			b.WriteString(initialism)
+			continue
+		}
+
+		b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+
+	return b.String()
+}
+
+// lowerFirst переводит описание из схемы в стиль комментариев Go: "Права пользователя" -> "права пользователя".
+// Аббревиатуры (UUID ресурса) не меняются.
+func lowerFirst(s string) string {
+	runes := []rune(s)
+	if len(runes) == 0 || (len(runes) > 1 && unicode.IsUpper(runes[1])) {
+		return s
+	}
+
+	runes[0] = unicode.ToLower(runes[0])
+
+	return string(runes)
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	authzv1 "github.com/olezhek28/access_policy/pkg/api/authz/v1"
+	"github.com/olezhek28/access_policy/pkg/policy"
+)
+
+// checkResult Итоговый результат политики final_check.result
+type checkResult struct {
+	Action             string             `rego:"action"`
+	AccessAllowed      bool               `rego:"access_allowed"`
+	ResourceValid      bool               `rego:"resource_valid"`
+	PermissionsGranted bool               `rego:"permissions_granted"`
+	MissingPermissions []string           `rego:"missing_permissions"`
+	Violations         []policy.Violation `rego:"violations,optional"`
+}
+
+// authzServer gRPC-API поверх того же сервиса решений, что и HTTP
+type authzServer struct {
+	authzv1.UnimplementedAuthzServiceServer
+
+	decisions *decisionService
+	// catalog сообщения о нарушениях. nil - в ответе подсказки из политики.
+	catalog *policy.Catalog
+}
+
+func newAuthzServer(decisions *decisionService, catalog *policy.Catalog) *authzServer {
+	return &authzServer{
+		decisions: decisions,
+		catalog:   catalog,
+	}
+}
+
+func (s *authzServer) Check(ctx context.Context, req *authzv1.CheckRequest) (*authzv1.CheckResponse, error) {
+	return s.check(ctx, req)
+}
+
+func (s *authzServer) CheckStream(stream authzv1.AuthzService_CheckStreamServer) error {
+	for {
+		req, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		// Ошибка одного запроса, например некорректные входные данные, возвращается в его ответе,
+		// чтобы не обрывать поток и не терять запросы, отправленные следом
+		resp, err := s.check(stream.Context(), req)
+		if err != nil {
+			resp = &authzv1.CheckResponse{
+				RequestId: req.GetRequestId(),
+				Error:     newCheckError(err),
+			}
+		}
+
+		if err = stream.Send(resp); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *authzServer) check(ctx context.Context, req *authzv1.CheckRequest) (*authzv1.CheckResponse, error) {
+	input := map[string]interface{}{
+		"source_uuid":      req.GetSourceUuid(),
+		"source_slug":      req.GetSourceSlug(),
+		"user_permissions": req.GetUserPermissions(),
+		"action":           req.GetAction(),
+	}
+
+	decision, err := s.decisions.decide(ctx, "final_check", "result", input)
+	var inputErr *policy.InputError
+	if errors.As(err, &inputErr) {
+		return nil, invalidInputStatus(inputErr)
+	}
+	if err != nil {
+		slog.Error("ошибка при вычислении решения", slog.String("request_id", req.GetRequestId()), slog.Any("error", err))
+		return nil, status.Errorf(codes.Internal, "ошибка при проверке доступа: %v", err)
+	}
+
+	result, err := policy.Decode[checkResult](decision)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "некорректный результат политики: %v", err)
+	}
+
+	// Подсказки переводятся на язык из метаданных accept-language, например "en" или "en-US,en;q=0.9"
+	if s.catalog != nil {
+		result.Violations = s.catalog.Localize(locale(ctx), result.Violations)
+	}
+
+	violations := make([]*authzv1.Violation, 0, len(result.Violations))
+	for _, v := range result.Violations {
+		violation, err := newViolation(v)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "некорректный результат политики: %v", err)
+		}
+
+		violations = append(violations, violation)
+	}
+
+	return &authzv1.CheckResponse{
+		RequestId:          req.GetRequestId(),
+		DecisionId:         decision.ID,
+		AccessAllowed:      result.AccessAllowed,
+		ResourceValid:      result.ResourceValid,
+		PermissionsGranted: result.PermissionsGranted,
+		MissingPermissions: result.MissingPermissions,
+		Action:             result.Action,
+		Violations:         violations,
+	}, nil
+}
+
+// invalidInputStatus статус InvalidArgument с нарушениями схемы входных данных в деталях BadRequest
+func invalidInputStatus(inputErr *policy.InputError) error {
+	st := status.New(codes.InvalidArgument, inputErr.Error())
+
+	badRequest := &errdetails.BadRequest{}
+	for _, fe := range inputErr.Errors {
+		badRequest.FieldViolations = append(badRequest.FieldViolations, &errdetails.BadRequest_FieldViolation{
+			Field:       fe.Field,
+			Description: fe.Message,
+		})
+	}
+
+	withDetails, err := st.WithDetails(badRequest)
+	if err != nil {
+		return st.Err()
+	}
+
+	return withDetails.Err()
+}
+
+// newCheckError ошибка проверки запроса для ответа в потоке с кодом и нарушениями схемы из статуса gRPC
+func newCheckError(err error) *authzv1.CheckError {
+	st := status.Convert(err)
+
+	checkErr := &authzv1.CheckError{
+		Code:    int32(st.Code()),
+		Message: st.Message(),
+	}
+	for _, detail := range st.Details() {
+		badRequest, ok := detail.(*errdetails.BadRequest)
+		if !ok {
+			continue
+		}
+
+		for _, fv := range badRequest.GetFieldViolations() {
+			checkErr.FieldViolations = append(checkErr.FieldViolations, &authzv1.FieldViolation{
+				Field:       fv.GetField(),
+				Description: fv.GetDescription(),
+			})
+		}
+	}
+
+	return checkErr
+}
+
+// locale язык клиента из метаданных запроса
+func locale(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+
+	return strings.Join(md.Get("accept-language"), ",")
+}
+
+func newViolation(v policy.Violation) (*authzv1.Violation, error) {
+	expected, err := newValue(v.Expected)
+	if err != nil {
+		return nil, fmt.Errorf("violations.%s.expected: %w", v.Field, err)
+	}
+
+	actual, err := newValue(v.Actual)
+	if err != nil {
+		return nil, fmt.Errorf("violations.%s.actual: %w", v.Field, err)
+	}
+
+	return &authzv1.Violation{
+		Code:     v.Code,
+		Field:    v.Field,
+		Expected: expected,
+		Actual:   actual,
+		Hint:     v.Hint,
+	}, nil
+}
+
+// newValue преобразует JSON-значение из результата политики в google.protobuf.Value.
+// Значения переводятся через JSON, так как числа OPA возвращает как json.Number.
+func newValue(v interface{}) (*structpb.Value, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	value := &structpb.Value{}
+	if err = value.UnmarshalJSON(raw); err != nil {
+		return nil, err
+	}
+
+	return value, nil
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+
+	authzv1 "github.com/olezhek28/access_policy/pkg/api/authz/v1"
+	"github.com/olezhek28/access_policy/pkg/authzclient"
+)
+
+// newTestClient поднимает gRPC-сервер в памяти поверх сервиса решений для политик cmd/4_complex_policy
+func newTestClient(t *testing.T) *authzclient.Client {
+	t.Helper()
+
+	lis := bufconn.Listen(1 << 20)
+	server := grpc.NewServer()
+	authzv1.RegisterAuthzServiceServer(server, newAuthzServer(newTestService(t), nil))
+	go server.Serve(lis)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("ошибка подключения к gRPC-серверу: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return authzclient.New(conn)
+}
+
+func TestCheckStream(t *testing.T) {
+	client := newTestClient(t)
+
+	reqs := []*authzv1.CheckRequest{
+		{
+			RequestId:       "1",
+			Action:          "read",
+			SourceUuid:      "0FF8AFB4-55D2-4836-B17C-643AD59BBB2F",
+			SourceSlug:      "some_slug",
+			UserPermissions: []string{"read"},
+		},
+		{
+			// Идентификатор ресурса не UUID: запрос не проходит проверку по схеме
+			RequestId:       "2",
+			Action:          "read",
+			SourceUuid:      "invalid_uuid",
+			SourceSlug:      "some_slug",
+			UserPermissions: []string{"read"},
+		},
+		{
+			RequestId:       "3",
+			Action:          "delete",
+			SourceUuid:      "0FF8AFB4-55D2-4836-B17C-643AD59BBB2F",
+			SourceSlug:      "some_slug",
+			UserPermissions: []string{"read"},
+		},
+	}
+
+	resps, err := client.CheckBatch(context.Background(), reqs)
+	if err != nil {
+		t.Fatalf("CheckBatch: %v", err)
+	}
+
+	for i, resp := range resps {
+		if resp.GetRequestId() != reqs[i].GetRequestId() {
+			t.Errorf("ответ %d для запроса %q, ожидался %q", i, resp.GetRequestId(), reqs[i].GetRequestId())
+		}
+	}
+
+	if resps[0].GetError() != nil || !resps[0].GetAccessAllowed() {
+		t.Errorf("запрос 1: ожидался доступ без ошибки, получено %v", resps[0])
+	}
+
+	checkErr := resps[1].GetError()
+	if codes.Code(checkErr.GetCode()) != codes.InvalidArgument {
+		t.Fatalf("запрос 2: ожидалась ошибка InvalidArgument, получено %v", resps[1])
+	}
+	if len(checkErr.GetFieldViolations()) == 0 || checkErr.GetFieldViolations()[0].GetField() != "source_uuid" {
+		t.Errorf("запрос 2: ожидалось нарушение схемы в поле source_uuid, получено %v", checkErr.GetFieldViolations())
+	}
+
+	// Запрос после некорректного обрабатывается в том же потоке
+	if resps[2].GetError() != nil || resps[2].GetAccessAllowed() {
+		t.Errorf("запрос 3: ожидался отказ без ошибки, получено %v", resps[2])
+	}
+}
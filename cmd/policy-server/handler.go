@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/olezhek28/access_policy/pkg/policy"
+)
+
+// maxRequestBodySize ограничение на размер тела запроса с входными данными
+const maxRequestBodySize = 1 << 20
+
+// decisionRequest тело запроса POST /v1/decisions/{package}/{rule}.
+// Составной пакет передается через точку: /v1/decisions/authz.documents/allow.
+type decisionRequest struct {
+	Input interface{} `json:"input"`
+	// Print включает вывод print() из политик в лог сервиса для этого запроса
+	Print bool `json:"print,omitempty"`
+}
+
+// decisionResponse ответ с документом решения.
+// Если правило не определено для входных данных, result отсутствует.
+type decisionResponse struct {
+	DecisionID string      `json:"decision_id"`
+	Result     interface{} `json:"result,omitempty"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+	// Errors нарушения схемы входных данных по полям
+	Errors []policy.FieldError `json:"errors,omitempty"`
+}
+
+func newHandler(decisions *decisionService) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/decisions/{package}/{rule}", func(w http.ResponseWriter, r *http.Request) {
+		handleDecision(w, r, decisions)
+	})
+
+	return mux
+}
+
+func handleDecision(w http.ResponseWriter, r *http.Request, decisions *decisionService) {
+	var req decisionRequest
+
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBodySize))
+	// Числа передаются в OPA как json.Number, чтобы не терять точность
+	decoder.UseNumber()
+	if err := decoder.Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "некорректное тело запроса: " + err.Error()})
+		return
+	}
+
+	var opts []policy.EvalOption
+	if req.Print {
+		opts = append(opts, policy.EvalPrint(slog.Default()))
+	}
+
+	decision, err := decisions.decide(r.Context(), r.PathValue("package"), r.PathValue("rule"), req.Input, opts...)
+	if err != nil {
+		var unknownQuery *unknownQueryError
+		if errors.As(err, &unknownQuery) {
+			writeJSON(w, http.StatusNotFound, errorResponse{Error: err.Error()})
+			return
+		}
+
+		// Входные данные не прошли проверку по схеме: политика не вычислялась, это не отказ в доступе
+		var inputErr *policy.InputError
+		if errors.As(err, &inputErr) {
+			writeJSON(w, http.StatusUnprocessableEntity, errorResponse{Error: err.Error(), Errors: inputErr.Errors})
+			return
+		}
+
+		slog.Error("ошибка при вычислении решения", slog.String("path", r.URL.Path), slog.Any("error", err))
+		writeJSON(w, http.StatusInternalServerError, errorResponse{Error: err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, decisionResponse{
+		DecisionID: decision.ID,
+		Result:     decision.Value,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		slog.Error("ошибка при записи ответа", slog.Any("error", err))
+	}
+}
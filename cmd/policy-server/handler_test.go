@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newTestService сервис решений для политик примера cmd/4_complex_policy
+func newTestService(t *testing.T) *decisionService {
+	t.Helper()
+
+	decisions := newDecisionService(
+		[]string{"../4_complex_policy"},
+		[]string{"../4_complex_policy/data.json"},
+		"../4_complex_policy/schemas/input.json",
+	)
+	if err := decisions.validate(context.Background()); err != nil {
+		t.Fatalf("ошибка при загрузке политик: %v", err)
+	}
+
+	return decisions
+}
+
+func TestHandleDecision(t *testing.T) {
+	decisions := newTestService(t)
+	handler := newHandler(decisions)
+
+	const input = `{"input": {"action": "read", "source_uuid": "0FF8AFB4-55D2-4836-B17C-643AD59BBB2F", "source_slug": "some_slug", "user_permissions": ["read"]}}`
+
+	tests := []struct {
+		name       string
+		path       string
+		body       string
+		wantStatus int
+	}{
+		{name: "правило из политик", path: "/v1/decisions/final_check/result", body: input, wantStatus: http.StatusOK},
+		{name: "неизвестное правило", path: "/v1/decisions/final_check/no_such_rule", body: input, wantStatus: http.StatusNotFound},
+		{name: "неизвестный пакет", path: "/v1/decisions/no_such_package/result", body: input, wantStatus: http.StatusNotFound},
+		{name: "имя правила не из политик", path: "/v1/decisions/final_check/1result", body: input, wantStatus: http.StatusNotFound},
+		{name: "некорректные входные данные", path: "/v1/decisions/final_check/result", body: `{"input": {"action": "read"}}`, wantStatus: http.StatusUnprocessableEntity},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body)))
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("статус %d, ожидался %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if rec.Code != http.StatusOK {
+				return
+			}
+
+			var resp decisionResponse
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatalf("ошибка разбора ответа: %v", err)
+			}
+			if resp.DecisionID == "" || resp.Result == nil {
+				t.Errorf("в ответе нет решения: %+v", resp)
+			}
+		})
+	}
+
+	// Движки создаются только для правил из политик
+	decisions.mu.RLock()
+	defer decisions.mu.RUnlock()
+	if len(decisions.engines) != 1 {
+		t.Errorf("создано движков %d, ожидался 1", len(decisions.engines))
+	}
+}
+
+// TestHandleDecisionDottedPackage проверяет запрос к правилу из пакета с составным путем
+func TestHandleDecisionDottedPackage(t *testing.T) {
+	dir := t.TempDir()
+	writePolicy(t, filepath.Join(dir, "documents.rego"), `package authz.documents
+
+default allow = false
+
+allow {
+	input.role == "admin"
+}
+`)
+
+	decisions := newDecisionService([]string{dir}, nil, "")
+	if err := decisions.validate(context.Background()); err != nil {
+		t.Fatalf("ошибка при загрузке политик: %v", err)
+	}
+	handler := newHandler(decisions)
+
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus int
+	}{
+		{name: "составной пакет", path: "/v1/decisions/authz.documents/allow", wantStatus: http.StatusOK},
+		{name: "родительский пакет", path: "/v1/decisions/authz/documents", wantStatus: http.StatusNotFound},
+		{name: "неполный путь пакета", path: "/v1/decisions/documents/allow", wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(`{"input": {"role": "admin"}}`)))
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("статус %d, ожидался %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+		})
+	}
+}
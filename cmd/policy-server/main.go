@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"google.golang.org/grpc"
+
+	authzv1 "github.com/olezhek28/access_policy/pkg/api/authz/v1"
+	"github.com/olezhek28/access_policy/pkg/policy"
+	"github.com/olezhek28/access_policy/pkg/policy/decisionlog"
+)
+
+var (
+	httpAddr = flag.String("http-addr", ":8080", "адрес HTTP-сервера")
+	grpcAddr = flag.String("grpc-addr", ":9090", "адрес gRPC-сервера")
+	policies = flag.String("policies", "cmd/4_complex_policy", "пути к rego-файлам или директориям с ними через запятую")
+	data     = flag.String("data", "cmd/4_complex_policy/data.json", "пути к JSON/YAML-файлам с данными или директориям с ними через запятую")
+	printOut = flag.Bool("print", false, "выводить print() из политик в лог для всех запросов")
+	watch    = flag.Bool("watch", false, "перезагружать политики при изменении файлов")
+	schema   = flag.String("input-schema", "cmd/4_complex_policy/schemas/input.json", "путь к JSON Schema входных данных, пусто - входные данные не проверяются")
+	messages = flag.String("messages", "messages", "директория с каталогом сообщений о нарушениях (ru.json, en.json и т.д.), пусто - подсказки из политики")
+
+	decisionLog     = flag.String("decision-log", "", "путь к файлу журнала решений в формате JSONL, пусто - журнал не ведется")
+	decisionLogMask = flag.String("decision-log-mask", "", "пути полей, удаляемых из журнала решений, через запятую, например input.source_uuid")
+)
+
+func main() {
+	flag.Parse()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var engineOpts []policy.Option
+	if *printOut {
+		engineOpts = append(engineOpts, policy.WithPrintLogger(slog.Default()))
+	}
+
+	if *decisionLog != "" {
+		sink, err := decisionlog.NewFileSink(*decisionLog)
+		if err != nil {
+			log.Fatalf("ошибка при открытии журнала решений: %v", err)
+		}
+		defer sink.Close()
+
+		engineOpts = append(engineOpts,
+			policy.WithDecisionLog(sink),
+			policy.WithDecisionMask(splitList(*decisionLogMask)...),
+		)
+	}
+
+	decisions := newDecisionService(splitList(*policies), splitList(*data), *schema, engineOpts...)
+
+	// Компилируем политики при старте, чтобы не запускать сервер с ошибками в политиках
+	if err := decisions.validate(ctx); err != nil {
+		log.Fatalf("ошибка при загрузке политик: %v", err)
+	}
+
+	if *watch {
+		go func() {
+			watcher := policy.NewWatcher(decisions.watchPaths(), decisions)
+			if err := watcher.Run(ctx); err != nil {
+				slog.Error("ошибка наблюдения за политиками", slog.Any("error", err))
+			}
+		}()
+	}
+
+	httpServer := &http.Server{
+		Addr:              *httpAddr,
+		Handler:           newHandler(decisions),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	var catalog *policy.Catalog
+	if *messages != "" {
+		var err error
+		if catalog, err = policy.LoadCatalog(*messages); err != nil {
+			log.Fatalf("ошибка при загрузке каталога сообщений: %v", err)
+		}
+	}
+
+	grpcServer := grpc.NewServer()
+	authzv1.RegisterAuthzServiceServer(grpcServer, newAuthzServer(decisions, catalog))
+
+	lis, err := net.Listen("tcp", *grpcAddr)
+	if err != nil {
+		log.Fatalf("ошибка при открытии порта gRPC-сервера: %v", err)
+	}
+
+	go func() {
+		<-ctx.Done()
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		if err := httpServer.Shutdown(shutdownCtx); err != nil {
+			slog.Error("ошибка при остановке HTTP-сервера", slog.Any("error", err))
+		}
+		grpcServer.GracefulStop()
+	}()
+
+	go func() {
+		slog.Info("gRPC-сервер запущен", slog.String("addr", *grpcAddr))
+		if err := grpcServer.Serve(lis); err != nil {
+			log.Fatalf("ошибка gRPC-сервера: %v", err)
+		}
+	}()
+
+	slog.Info("HTTP-сервер запущен", slog.String("addr", *httpAddr))
+	if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Fatalf("ошибка HTTP-сервера: %v", err)
+	}
+}
+
+func splitList(s string) []string {
+	if s == "" {
+		return nil
+	}
+
+	return strings.Split(s, ",")
+}
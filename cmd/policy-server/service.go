@@ -0,0 +1,237 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"golang.org/x/sync/singleflight"
+
+	"github.com/olezhek28/access_policy/pkg/policy"
+)
+
+// decisionService вычисляет решения по любому правилу из загруженных политик.
+// Для каждого запроса (пакет + правило) создается свой движок, который компилируется один раз.
+// Запросы ограничены правилами, найденными в политиках при загрузке, поэтому клиент не может
+// заставить сервис компилировать и хранить движки для произвольных имен.
+// Движки собираются из последней корректной версии политик, данных и схемы входных данных, а не из файлов,
+// поэтому движок, впервые запрошенный после неудачного изменения любого из файлов, тоже работает с корректной версией.
+type decisionService struct {
+	paths      []string
+	dataPaths  []string
+	schemaPath string
+	engineOpts []policy.Option
+
+	// group объединяет конкурентные компиляции движка для одного правила
+	group singleflight.Group
+
+	mu      sync.RWMutex
+	engines map[string]*policy.Engine
+	// policies последняя корректная версия политик
+	policies *policySet
+}
+
+// policySet политики, которые скомпилировались вместе с данными и схемой входных данных, и запросы к их правилам
+type policySet struct {
+	modules []policy.Module
+	// data документ data из файлов с данными. nil, если файлы не заданы.
+	data map[string]interface{}
+	// schema схема входных данных. nil, если схема не задана.
+	schema *policy.InputSchema
+	// queries запросы к правилам политик, например data.final_check.result
+	queries map[string]bool
+}
+
+func newDecisionService(paths, dataPaths []string, schemaPath string, engineOpts ...policy.Option) *decisionService {
+	return &decisionService{
+		paths:      paths,
+		dataPaths:  dataPaths,
+		schemaPath: schemaPath,
+		engineOpts: engineOpts,
+		engines:    make(map[string]*policy.Engine),
+		policies:   &policySet{},
+	}
+}
+
+// watchPaths возвращает пути к загружаемым политикам, данным и схеме входных данных
+func (s *decisionService) watchPaths() []string {
+	paths := append(append([]string{}, s.paths...), s.dataPaths...)
+	if s.schemaPath != "" {
+		paths = append(paths, s.schemaPath)
+	}
+
+	return paths
+}
+
+// validate загружает и компилирует все политики, чтобы обнаружить ошибки до приема запросов
+func (s *decisionService) validate(ctx context.Context) error {
+	policies, err := s.load(ctx)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.policies = policies
+	s.mu.Unlock()
+
+	return nil
+}
+
+// load загружает политики, данные и схему входных данных из файлов и проверяет, что политики компилируются с ними
+func (s *decisionService) load(ctx context.Context) (*policySet, error) {
+	modules, err := policy.LoadModules(s.paths...)
+	if err != nil {
+		return nil, err
+	}
+
+	policies := &policySet{
+		modules: modules,
+	}
+
+	if len(s.dataPaths) > 0 {
+		if policies.data, err = policy.LoadDataFiles(s.dataPaths...); err != nil {
+			return nil, err
+		}
+	}
+
+	if s.schemaPath != "" {
+		if policies.schema, err = policy.LoadInputSchema(s.schemaPath); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = s.newEngine("data", policies).Prepare(ctx); err != nil {
+		return nil, err
+	}
+
+	rules, err := policy.ModuleRules(modules...)
+	if err != nil {
+		return nil, err
+	}
+
+	policies.queries = make(map[string]bool, len(rules))
+	for _, rule := range rules {
+		policies.queries[rule] = true
+	}
+
+	return policies, nil
+}
+
+func (s *decisionService) newEngine(query string, policies *policySet) *policy.Engine {
+	opts := []policy.Option{
+		policy.WithModules(policies.modules...),
+	}
+	if policies.data != nil {
+		opts = append(opts, policy.WithData(policies.data))
+	}
+	if policies.schema != nil {
+		opts = append(opts, policy.WithLoadedInputSchema(policies.schema))
+	}
+	opts = append(opts, s.engineOpts...)
+
+	return policy.New(query, opts...)
+}
+
+// Reload загружает политики после их изменения и пересобирает из них созданные движки.
+// Если новая версия не компилируется, сервис продолжает работать с последней корректной версией.
+// Движки компилируются без блокировки и подменяются все сразу, поэтому решения во время перезагрузки не ждут ее.
+func (s *decisionService) Reload(ctx context.Context) error {
+	policies, err := s.load(ctx)
+	if err != nil {
+		return err
+	}
+
+	s.mu.RLock()
+	queries := make([]string, 0, len(s.engines))
+	for query := range s.engines {
+		queries = append(queries, query)
+	}
+	s.mu.RUnlock()
+
+	// Движки для правил, которых больше нет в политиках, удаляются
+	engines := make(map[string]*policy.Engine, len(queries))
+	var errs []error
+	for _, query := range queries {
+		if !policies.queries[query] {
+			continue
+		}
+
+		engine := s.newEngine(query, policies)
+		if err = engine.Prepare(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", query, err))
+			continue
+		}
+
+		engines[query] = engine
+	}
+
+	s.mu.Lock()
+	s.policies = policies
+	s.engines = engines
+	s.mu.Unlock()
+
+	return errors.Join(errs...)
+}
+
+// decide вычисляет правило rule пакета pkg для входных данных input
+func (s *decisionService) decide(ctx context.Context, pkg, rule string, input interface{}, opts ...policy.EvalOption) (policy.Decision, error) {
+	engine, err := s.engine(ctx, pkg, rule)
+	if err != nil {
+		return policy.Decision{}, err
+	}
+
+	return engine.Eval(ctx, input, opts...)
+}
+
+// engine возвращает скомпилированный движок для правила. Движок попадает в кеш только после успешной компиляции.
+// Пакет может быть составным (a.b): запрос сверяется с правилами политик, а не с шаблоном имени,
+// поэтому из пути запроса нельзя собрать ничего, кроме правила загруженной политики.
+func (s *decisionService) engine(ctx context.Context, pkg, rule string) (*policy.Engine, error) {
+	query := fmt.Sprintf("data.%s.%s", pkg, rule)
+
+	s.mu.RLock()
+	engine, ok := s.engines[query]
+	policies := s.policies
+	s.mu.RUnlock()
+	if ok {
+		return engine, nil
+	}
+	if !policies.queries[query] {
+		return nil, &unknownQueryError{query: query}
+	}
+
+	// Движок компилируется без блокировки, чтобы не задерживать решения по другим правилам.
+	// Результат получают все ожидающие запросы к правилу, поэтому отмена ctx первого из них не прерывает компиляцию.
+	v, err, _ := s.group.Do(query, func() (interface{}, error) {
+		engine := s.newEngine(query, policies)
+		if err := engine.Prepare(context.WithoutCancel(ctx)); err != nil {
+			return nil, err
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		// Пока движок компилировался, политики могли перезагрузиться: движок из предыдущей версии
+		// отвечает на этот запрос, но не сохраняется
+		if s.policies == policies {
+			s.engines[query] = engine
+		}
+
+		return engine, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return v.(*policy.Engine), nil
+}
+
+// unknownQueryError запрос к правилу, которого нет в загруженных политиках
+type unknownQueryError struct {
+	query string
+}
+
+func (e *unknownQueryError) Error() string {
+	return fmt.Sprintf("правило %s не найдено в политиках", e.query)
+}
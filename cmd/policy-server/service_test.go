@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/olezhek28/access_policy/pkg/policy"
+)
+
+const (
+	goodPolicy = `package authz
+
+default allow = false
+
+allow {
+	input.role == "admin"
+}
+
+default deny = true
+`
+	badPolicy = `package authz
+
+allow {
+	input.role ==
+}
+`
+	updatedPolicy = `package authz
+
+default allow = false
+
+allow {
+	input.role == "manager"
+}
+
+default deny = true
+`
+)
+
+// TestReloadKeepsLastGoodPolicies проверяет, что после изменения политики с ошибкой сервис продолжает
+// работать с последней корректной версией, в том числе для движков, впервые запрошенных после ошибки
+func TestReloadKeepsLastGoodPolicies(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "authz.rego")
+	writePolicy(t, path, goodPolicy)
+
+	decisions := newDecisionService([]string{dir}, nil, "")
+	if err := decisions.validate(ctx); err != nil {
+		t.Fatalf("ошибка при загрузке политик: %v", err)
+	}
+
+	assertDecision(t, decisions, "allow", "admin", true)
+
+	reloads := make(chan error, 10)
+	watcher := policy.NewWatcher(decisions.watchPaths(), decisions,
+		policy.WithDebounce(10*time.Millisecond),
+		policy.WithReloadHook(func(err error) { reloads <- err }),
+	)
+	go watcher.Run(ctx)
+	// Наблюдатель начинает отслеживать файлы не сразу после запуска
+	time.Sleep(50 * time.Millisecond)
+
+	writePolicy(t, path, badPolicy)
+	if err := waitReload(t, reloads); err == nil {
+		t.Fatal("политика с ошибкой перезагружена без ошибки")
+	}
+
+	// Уже созданный движок и движок для правила, которое раньше не запрашивалось, работают с последней корректной версией
+	assertDecision(t, decisions, "allow", "admin", true)
+	assertDecision(t, decisions, "deny", "admin", true)
+
+	writePolicy(t, path, updatedPolicy)
+	if err := waitReload(t, reloads); err != nil {
+		t.Fatalf("ошибка при перезагрузке исправленной политики: %v", err)
+	}
+
+	assertDecision(t, decisions, "allow", "admin", false)
+	assertDecision(t, decisions, "allow", "manager", true)
+}
+
+// TestReloadKeepsLastGoodDataAndSchema проверяет, что файл с данными или схема входных данных с ошибкой
+// не ломают ни созданные движки, ни движки для правил, впервые запрошенных после ошибки
+func TestReloadKeepsLastGoodDataAndSchema(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	dir := t.TempDir()
+	dataPath := filepath.Join(dir, "data", "data.json")
+	schemaPath := filepath.Join(dir, "input.json")
+	if err := os.Mkdir(filepath.Dir(dataPath), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	writePolicy(t, filepath.Join(dir, "authz.rego"), `package authz
+
+default allow = false
+
+allow {
+	data.admins[_] == input.role
+}
+
+default deny = true
+
+default audit = true
+`)
+	const data = `{"admins": ["admin"]}`
+	const schema = `{"type": "object", "properties": {"role": {"type": "string"}}, "required": ["role"]}`
+	writePolicy(t, dataPath, data)
+	writePolicy(t, schemaPath, schema)
+
+	decisions := newDecisionService([]string{filepath.Join(dir, "authz.rego")}, []string{filepath.Dir(dataPath)}, schemaPath)
+	if err := decisions.validate(ctx); err != nil {
+		t.Fatalf("ошибка при загрузке политик: %v", err)
+	}
+
+	assertDecision(t, decisions, "allow", "admin", true)
+
+	reloads := make(chan error, 10)
+	watcher := policy.NewWatcher(decisions.watchPaths(), decisions,
+		policy.WithDebounce(10*time.Millisecond),
+		policy.WithReloadHook(func(err error) { reloads <- err }),
+	)
+	go watcher.Run(ctx)
+	time.Sleep(50 * time.Millisecond)
+
+	writePolicy(t, dataPath, `{"admins": [`)
+	if err := waitReload(t, reloads); err == nil {
+		t.Fatal("данные с ошибкой перезагружены без ошибки")
+	}
+
+	assertDecision(t, decisions, "allow", "admin", true)
+	assertDecision(t, decisions, "deny", "admin", true)
+
+	writePolicy(t, dataPath, data)
+	if err := waitReload(t, reloads); err != nil {
+		t.Fatalf("ошибка при перезагрузке исправленных данных: %v", err)
+	}
+
+	writePolicy(t, schemaPath, `{"type": "object",`)
+	if err := waitReload(t, reloads); err == nil {
+		t.Fatal("схема с ошибкой перезагружена без ошибки")
+	}
+
+	assertDecision(t, decisions, "allow", "admin", true)
+	assertDecision(t, decisions, "audit", "admin", true)
+
+	// Входные данные по-прежнему проверяются по последней корректной схеме
+	var inputErr *policy.InputError
+	if _, err := decisions.decide(ctx, "authz", "audit", map[string]interface{}{}); !errors.As(err, &inputErr) {
+		t.Errorf("входные данные без role: ошибка %v, ожидалась *policy.InputError", err)
+	}
+}
+
+func writePolicy(t *testing.T, path, source string) {
+	t.Helper()
+
+	if err := os.WriteFile(path, []byte(source), 0o644); err != nil {
+		t.Fatalf("ошибка записи политики: %v", err)
+	}
+}
+
+func waitReload(t *testing.T, reloads <-chan error) error {
+	t.Helper()
+
+	select {
+	case err := <-reloads:
+		return err
+	case <-time.After(5 * time.Second):
+		t.Fatal("политики не перезагружены после изменения файла")
+		return nil
+	}
+}
+
+func assertDecision(t *testing.T, decisions *decisionService, rule, role string, want bool) {
+	t.Helper()
+
+	decision, err := decisions.decide(context.Background(), "authz", rule, map[string]interface{}{"role": role})
+	if err != nil {
+		t.Fatalf("%s для роли %s: %v", rule, role, err)
+	}
+
+	got, err := decision.Bool()
+	if err != nil {
+		t.Fatalf("%s для роли %s: %v", rule, role, err)
+	}
+	if got != want {
+		t.Errorf("%s для роли %s: получено %v, ожидалось %v", rule, role, got, want)
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+
+	"github.com/olezhek28/access_policy/pkg/policy"
+	"github.com/olezhek28/access_policy/pkg/policy/cases"
+)
+
+func runCases(ctx context.Context, args []string) error {
+	fs := newFlagSet("cases", "<запрос> <файлы кейсов...>",
+		"Вычисляет запрос для входных данных каждого кейса из YAML/JSON-файлов, сравнивает результат с ожидаемым\n"+
+			"и выводит таблицу PASS/FAIL с отличиями. Формат файла кейсов описан в пакете pkg/policy/cases.\n"+
+			"Пример: policyctl cases -d cmd/4_complex_policy -data cmd/4_complex_policy/data.json "+
+			"-schema cmd/4_complex_policy/schemas/input.json data.final_check.result cmd/4_complex_policy/cases.yaml")
+
+	var policies, dataFiles listFlag
+	fs.Var(&policies, "d", "rego-файлы или директории с ними, можно передать несколько раз (по-умолчанию текущая директория)")
+	fs.Var(&dataFiles, "data", "JSON/YAML-файлы с данными или директории с ними, можно передать несколько раз")
+	schema := fs.String("schema", "", "JSON Schema входных данных, нужна для кейсов с expect_input_error")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() < 2 {
+		fs.Usage()
+		return errors.New("нужно передать запрос и хотя бы один файл кейсов")
+	}
+
+	if len(policies) == 0 {
+		policies = listFlag{"."}
+	}
+
+	opts := []policy.Option{policy.WithFiles(policies...)}
+	if len(dataFiles) > 0 {
+		opts = append(opts, policy.WithDataFiles(dataFiles...))
+	}
+	if *schema != "" {
+		opts = append(opts, policy.WithInputSchema(*schema))
+	}
+
+	// Входные данные кейсов произвольной формы: policyctl не знает типов входных данных политики
+	loaded, err := cases.Load[interface{}](fs.Args()[1:]...)
+	if err != nil {
+		return err
+	}
+
+	report := cases.Run(ctx, policy.New(fs.Arg(0), opts...), loaded)
+	report.Print(os.Stdout)
+
+	if report.Failed() > 0 {
+		return errFailed
+	}
+
+	return nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/fatih/color"
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/open-policy-agent/opa/loader"
+
+	"github.com/olezhek28/access_policy/pkg/policy"
+)
+
+func runCheck(_ context.Context, args []string) error {
+	fs := newFlagSet("check", "[пути...]",
+		"Разбирает и компилирует все rego-файлы, включая тесты, и выводит ошибки с файлом и строкой.\n"+
+			"Пример: policyctl check -schema cmd/4_complex_policy/schemas/input.json cmd/4_complex_policy")
+
+	schema := fs.String("schema", "", "JSON Schema входных данных для проверки типов в политиках")
+	strict := fs.Bool("strict", false, "строгий режим компилятора OPA: неиспользуемые переменные, импорты и т.д. - ошибки")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	res, err := loader.AllRegos(pathsOrCurrent(fs))
+	if err != nil {
+		var loadErrs loader.Errors
+		if !errors.As(err, &loadErrs) {
+			return err
+		}
+
+		for _, loadErr := range loadErrs {
+			fmt.Println(color.RedString("%v", loadErr))
+		}
+		return errFailed
+	}
+
+	compiler := ast.NewCompiler().WithStrict(*strict)
+	if *schema != "" {
+		inputSchema, err := policy.LoadInputSchema(*schema)
+		if err != nil {
+			return err
+		}
+
+		compiler = compiler.WithSchemas(inputSchema.SchemaSet())
+	}
+
+	modules := res.ParsedModules()
+	if compiler.Compile(modules); compiler.Failed() {
+		for _, compileErr := range compiler.Errors {
+			fmt.Println(color.RedString("%v", compileErr))
+		}
+		fmt.Println()
+		fmt.Println(color.RedString("Ошибок компиляции: %d", len(compiler.Errors)))
+		return errFailed
+	}
+
+	fmt.Println(color.GreenString("Проверено файлов: %d, ошибок нет", len(modules)))
+
+	return nil
+}
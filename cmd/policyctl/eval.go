@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+
+	"github.com/fatih/color"
+	"github.com/open-policy-agent/opa/util"
+
+	"github.com/olezhek28/access_policy/pkg/policy"
+)
+
+func runEval(ctx context.Context, args []string) error {
+	fs := newFlagSet("eval", "<запрос>",
+		"Вычисляет запрос, например data.final_check.result, и выводит результат в JSON.\n"+
+			"Пример: policyctl eval -d cmd/4_complex_policy -data cmd/4_complex_policy/data.json -i cmd/4_complex_policy/input.json data.final_check.result")
+
+	var policies, dataFiles listFlag
+	fs.Var(&policies, "d", "rego-файлы или директории с ними, можно передать несколько раз (по-умолчанию текущая директория)")
+	fs.Var(&dataFiles, "data", "JSON/YAML-файлы с данными или директории с ними, можно передать несколько раз")
+	inputPath := fs.String("i", "", "файл с входными данными в JSON или YAML, - для чтения из stdin")
+	schema := fs.String("schema", "", "JSON Schema входных данных: данные проверяются перед вычислением, а типы политик - при компиляции")
+	printOut := fs.Bool("print", false, "выводить print() из политик в stderr")
+	compact := fs.Bool("compact", false, "выводить результат в одну строку")
+	explain := fs.Bool("explain", false, "выводить в stderr дерево вычисленных правил с невыполнившимися выражениями и их значениями")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("нужно передать один запрос")
+	}
+
+	if len(policies) == 0 {
+		policies = listFlag{"."}
+	}
+
+	opts := []policy.Option{policy.WithFiles(policies...)}
+	if len(dataFiles) > 0 {
+		opts = append(opts, policy.WithDataFiles(dataFiles...))
+	}
+	if *schema != "" {
+		opts = append(opts, policy.WithInputSchema(*schema))
+	}
+	if *printOut {
+		opts = append(opts, policy.WithPrintLogger(slog.New(slog.NewTextHandler(os.Stderr, nil))))
+	}
+
+	input, err := readInput(*inputPath)
+	if err != nil {
+		return err
+	}
+
+	engine := policy.New(fs.Arg(0), opts...)
+
+	var decision policy.Decision
+	if *explain {
+		var explanation policy.Explanation
+		if explanation, err = engine.Explain(ctx, input); err == nil {
+			decision = explanation.Decision
+			fmt.Fprint(os.Stderr, explanation)
+		}
+	} else {
+		decision, err = engine.Eval(ctx, input)
+	}
+
+	var inputErr *policy.InputError
+	if errors.As(err, &inputErr) {
+		fmt.Fprintln(os.Stderr, color.YellowString("Некорректные входные данные:"))
+		for _, fe := range inputErr.Errors {
+			fmt.Fprintf(os.Stderr, "- %s: %s\n", fe.Field, fe.Message)
+		}
+		return errFailed
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(os.Stderr, color.New(color.Faint).Sprintf("решение %s, ревизия %s", decision.ID, decision.Revision))
+
+	if !decision.Defined {
+		fmt.Fprintln(os.Stderr, color.YellowString("Результат не определен"))
+		return nil
+	}
+
+	var out []byte
+	if *compact {
+		out, err = json.Marshal(decision.Value)
+	} else {
+		out, err = json.MarshalIndent(decision.Value, "", "  ")
+	}
+	if err != nil {
+		return fmt.Errorf("ошибка сериализации результата: %w", err)
+	}
+
+	fmt.Println(string(out))
+
+	return nil
+}
+
+// readInput читает входные данные из файла или stdin. Без пути входных данных нет.
+func readInput(path string) (interface{}, error) {
+	var (
+		raw []byte
+		err error
+	)
+
+	switch path {
+	case "":
+		return nil, nil
+	case "-":
+		raw, err = io.ReadAll(os.Stdin)
+	default:
+		raw, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения входных данных: %w", err)
+	}
+
+	// util.Unmarshal разбирает и JSON, и YAML, а числа сохраняет как json.Number
+	var input interface{}
+	if err = util.Unmarshal(raw, &input); err != nil {
+		return nil, fmt.Errorf("ошибка разбора входных данных: %w", err)
+	}
+
+	return input, nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/fatih/color"
+	"github.com/open-policy-agent/opa/format"
+	"github.com/open-policy-agent/opa/loader"
+)
+
+func runFmt(_ context.Context, args []string) error {
+	fs := newFlagSet("fmt", "[пути...]",
+		"Форматирует rego-файлы так же, как opa fmt. Без флагов выводит отформатированные политики в stdout.\n"+
+			"Пример: policyctl fmt -l cmd")
+
+	write := fs.Bool("w", false, "записать отформатированные политики в файлы")
+	list := fs.Bool("l", false, "вывести файлы, форматирование которых отличается")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	files, err := loader.FilteredPaths(pathsOrCurrent(fs), regoFilesOnly)
+	if err != nil {
+		return err
+	}
+
+	failed := false
+	for _, file := range files {
+		src, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+
+		formatted, err := format.Source(file, src)
+		if err != nil {
+			fmt.Println(color.RedString("%s: %v", file, err))
+			failed = true
+			continue
+		}
+
+		changed := !bytes.Equal(src, formatted)
+
+		if *list && changed {
+			fmt.Println(file)
+		}
+
+		if *write && changed {
+			if err = os.WriteFile(file, formatted, 0o644); err != nil {
+				return err
+			}
+		}
+
+		if !*list && !*write {
+			fmt.Println(color.New(color.Faint).Sprintf("# %s", file))
+			fmt.Print(string(formatted))
+		}
+	}
+
+	if failed {
+		return errFailed
+	}
+
+	return nil
+}
+
+// regoFilesOnly фильтр загрузчика, который оставляет только rego-файлы
+func regoFilesOnly(_ string, info fs.FileInfo, _ int) bool {
+	return !info.IsDir() && filepath.Ext(info.Name()) != ".rego"
+}
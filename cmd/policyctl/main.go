@@ -0,0 +1,128 @@
+// policyctl утилита для работы с политиками без внешнего бинарника opa.
+// Все команды построены на том же пакете pkg/policy, что и примеры из cmd:
+//
+//	policyctl eval   вычислить запрос к политикам для входных данных из файла или stdin
+//	policyctl test   выполнить тесты политик *_test.rego
+//	policyctl cases  выполнить кейсы из YAML/JSON-файлов и сравнить результаты с ожидаемыми
+//	policyctl check  проверить, что политики в директории компилируются
+//	policyctl fmt    отформатировать политики
+//	policyctl render сгенерировать политики из шаблонов с данными PolicyData из JSON-файла
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// errFailed команда завершилась неуспешно, а подробности уже выведены (упавшие тесты, ошибки компиляции)
+var errFailed = errors.New("команда завершилась с ошибками")
+
+// command подкоманда policyctl
+type command struct {
+	name    string
+	summary string
+	run     func(ctx context.Context, args []string) error
+}
+
+var commands = []command{
+	{name: "eval", summary: "вычислить запрос к политикам", run: runEval},
+	{name: "test", summary: "выполнить тесты политик *_test.rego", run: runTest},
+	{name: "cases", summary: "выполнить кейсы из файлов и сравнить результаты", run: runCases},
+	{name: "check", summary: "проверить, что политики компилируются", run: runCheck},
+	{name: "fmt", summary: "отформатировать политики", run: runFmt},
+	{name: "render", summary: "сгенерировать политики из шаблонов", run: runRender},
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	name := os.Args[1]
+	if name == "help" || name == "-h" || name == "--help" {
+		usage()
+		return
+	}
+
+	for _, cmd := range commands {
+		if cmd.name != name {
+			continue
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		err := cmd.run(ctx, os.Args[2:])
+		stop()
+
+		switch {
+		case err == nil:
+			return
+		case errors.Is(err, flag.ErrHelp):
+			return
+		case errors.Is(err, errFailed):
+			os.Exit(1)
+		default:
+			fmt.Fprintln(os.Stderr, color.RedString("Ошибка: %v", err))
+			os.Exit(1)
+		}
+	}
+
+	fmt.Fprintln(os.Stderr, color.RedString("Неизвестная команда %q", name))
+	usage()
+	os.Exit(2)
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Использование: policyctl <команда> [флаги] [аргументы]")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Команды:")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-8s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Флаги команды: policyctl <команда> -h")
+}
+
+// newFlagSet набор флагов подкоманды с описанием в справке
+func newFlagSet(name, args, description string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Использование: policyctl %s [флаги] %s\n\n%s\n\nФлаги:\n", name, args, description)
+		fs.PrintDefaults()
+	}
+
+	return fs
+}
+
+// pathsOrCurrent пути из аргументов команды, по-умолчанию текущая директория
+func pathsOrCurrent(fs *flag.FlagSet) []string {
+	if fs.NArg() == 0 {
+		return []string{"."}
+	}
+
+	return fs.Args()
+}
+
+// listFlag флаг, который можно передать несколько раз или списком через запятую
+type listFlag []string
+
+func (l *listFlag) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *listFlag) Set(value string) error {
+	for _, v := range strings.Split(value, ",") {
+		if v != "" {
+			*l = append(*l, v)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// runMainEnv переменная окружения, с которой тестовый бинарник выполняет main вместо тестов
+const runMainEnv = "POLICYCTL_RUN_MAIN"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(runMainEnv) == "1" {
+		main()
+		os.Exit(0)
+	}
+
+	os.Exit(m.Run())
+}
+
+// result результат запуска policyctl
+type result struct {
+	code   int
+	stdout string
+	stderr string
+}
+
+// policyctl запускает команду в отдельном процессе: тестовый бинарник с runMainEnv выполняет main
+// с аргументами args, поэтому проверяются настоящие коды завершения, stdout и stderr
+func policyctl(t *testing.T, stdin string, args ...string) result {
+	t.Helper()
+
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Env = append(os.Environ(), runMainEnv+"=1")
+	cmd.Stdin = strings.NewReader(stdin)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		t.Fatalf("ошибка запуска policyctl: %v", err)
+	}
+
+	return result{
+		code:   cmd.ProcessState.ExitCode(),
+		stdout: stdout.String(),
+		stderr: stderr.String(),
+	}
+}
+
+func (r result) assert(t *testing.T, code int, stdout, stderr string) {
+	t.Helper()
+
+	if r.code != code {
+		t.Errorf("код завершения %d, ожидался %d\nstdout:\n%s\nstderr:\n%s", r.code, code, r.stdout, r.stderr)
+	}
+	if !strings.Contains(r.stdout, stdout) {
+		t.Errorf("в stdout нет %q:\n%s", stdout, r.stdout)
+	}
+	if !strings.Contains(r.stderr, stderr) {
+		t.Errorf("в stderr нет %q:\n%s", stderr, r.stderr)
+	}
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+const example = "../4_complex_policy"
+
+func TestEval(t *testing.T) {
+	evalArgs := []string{
+		"eval",
+		"-d", filepath.Join(example, "resource_check.rego"),
+		"-d", filepath.Join(example, "permission_check.rego"),
+		"-d", filepath.Join(example, "final_check.rego"),
+		"-data", filepath.Join(example, "data.json"),
+		"-schema", filepath.Join(example, "schemas", "input.json"),
+	}
+
+	tests := []struct {
+		name   string
+		stdin  string
+		args   []string
+		code   int
+		stdout string
+		stderr string
+	}{
+		{
+			name:   "входные данные из файла",
+			args:   []string{"-i", filepath.Join(example, "input.json"), "data.final_check.result"},
+			stdout: `"access_allowed": false`,
+			stderr: "решение ",
+		},
+		{
+			name:   "входные данные из stdin в одну строку",
+			stdin:  `{"action": "read", "source_uuid": "0FF8AFB4-55D2-4836-B17C-643AD59BBB2F", "source_slug": "some_slug", "user_permissions": ["read"]}`,
+			args:   []string{"-i", "-", "-compact", "data.final_check.result.access_allowed"},
+			stdout: "true\n",
+		},
+		{
+			name:   "неопределенный результат",
+			args:   []string{"-i", filepath.Join(example, "input.json"), "data.final_check.no_such_rule"},
+			stderr: "Результат не определен",
+		},
+		{
+			name:   "некорректные входные данные",
+			stdin:  `{"action": "read"}`,
+			args:   []string{"-i", "-", "data.final_check.result"},
+			code:   1,
+			stderr: "Некорректные входные данные:\n- source_uuid: source_uuid is required",
+		},
+		{
+			name:   "без запроса",
+			args:   []string{"-i", filepath.Join(example, "input.json")},
+			code:   1,
+			stderr: "нужно передать один запрос",
+		},
+		{
+			name:   "нет файла с входными данными",
+			args:   []string{"-i", "no_such_input.json", "data.final_check.result"},
+			code:   1,
+			stderr: "ошибка чтения входных данных",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policyctl(t, tt.stdin, append(evalArgs, tt.args...)...).assert(t, tt.code, tt.stdout, tt.stderr)
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "broken.rego"), "package broken\n\nallow {\n\tinput.role ==\n}\n")
+
+	undeclared := t.TempDir()
+	writeFile(t, filepath.Join(undeclared, "authz.rego"), "package authz\n\nallow {\n\tinput.role == \"admin\"\n}\n")
+
+	tests := []struct {
+		name   string
+		args   []string
+		code   int
+		stdout string
+	}{
+		{
+			name:   "политики компилируются со схемой",
+			args:   []string{"-schema", filepath.Join(example, "schemas", "input.json"), example},
+			stdout: "ошибок нет",
+		},
+		{
+			name:   "синтаксическая ошибка",
+			args:   []string{dir},
+			code:   1,
+			stdout: "broken.rego:5: rego_parse_error",
+		},
+		{
+			name:   "поле не объявлено в схеме",
+			args:   []string{"-schema", filepath.Join(example, "schemas", "input.json"), undeclared},
+			code:   1,
+			stdout: "undefined ref: input.role",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policyctl(t, "", append([]string{"check"}, tt.args...)...).assert(t, tt.code, tt.stdout, "")
+		})
+	}
+}
+
+func TestFmt(t *testing.T) {
+	dir := t.TempDir()
+	unformatted := filepath.Join(dir, "authz.rego")
+
+	const source = "package authz\ndefault allow=false\nallow { input.role==\"admin\" }\n"
+
+	writeFile(t, unformatted, source)
+
+	// Без флагов политика выводится отформатированной, а файл не меняется
+	res := policyctl(t, "", "fmt", unformatted)
+	res.assert(t, 0, "allow", "")
+	if got, _ := os.ReadFile(unformatted); string(got) != source {
+		t.Error("fmt без -w изменил файл")
+	}
+
+	// -w записывает форматирование в файл, после чего -l его не выводит
+	policyctl(t, "", "fmt", "-l", dir).assert(t, 0, unformatted, "")
+	policyctl(t, "", "fmt", "-w", dir).assert(t, 0, "", "")
+	if got, _ := os.ReadFile(unformatted); !strings.Contains(string(got), "default allow = false\n") {
+		t.Errorf("fmt -w не отформатировал файл:\n%s", got)
+	}
+
+	if res = policyctl(t, "", "fmt", "-l", dir); res.code != 0 || res.stdout != "" {
+		t.Errorf("после fmt -w файлы все еще требуют форматирования: %+v", res)
+	}
+
+	writeFile(t, filepath.Join(dir, "broken.rego"), "package broken\n\nallow {\n")
+	policyctl(t, "", "fmt", "-l", dir).assert(t, 1, "broken.rego", "")
+}
+
+func TestUnknownCommand(t *testing.T) {
+	policyctl(t, "", "no_such_command").assert(t, 2, "", "Неизвестная команда")
+	policyctl(t, "").assert(t, 2, "", "Использование: policyctl")
+}
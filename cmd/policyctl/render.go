@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/fatih/color"
+	"github.com/open-policy-agent/opa/util"
+
+	"github.com/olezhek28/access_policy/pkg/policy"
+)
+
+func runRender(_ context.Context, args []string) error {
+	fs := newFlagSet("render", "",
+		"Генерирует политики из шаблонов *.tmpl с данными из JSON/YAML-файла и проверяет, что они компилируются вместе.\n"+
+			"Поля данных совпадают с PolicyData из cmd/5_complex_policy_in_template: SourceUUID, SourceSlug, Actions, Implies.\n"+
+			"Пример: policyctl render -templates cmd/5_complex_policy_in_template -data cmd/5_complex_policy_in_template/policy_data.json")
+
+	templates := fs.String("templates", ".", "директория с шаблонами политик *.tmpl")
+	dataPath := fs.String("data", "", "JSON/YAML-файл с данными для шаблонов")
+	strict := fs.Bool("strict", true, "разрешать подставлять значения только через regoString, regoSet и regoNumber")
+	outDir := fs.String("o", "", "директория, в которую записываются политики. По-умолчанию политики выводятся в stdout.")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *dataPath == "" {
+		fs.Usage()
+		return errors.New("не задан файл с данными для шаблонов")
+	}
+
+	raw, err := os.ReadFile(*dataPath)
+	if err != nil {
+		return fmt.Errorf("ошибка чтения данных для шаблонов: %w", err)
+	}
+
+	var data interface{}
+	if err = util.Unmarshal(raw, &data); err != nil {
+		return fmt.Errorf("ошибка разбора данных для шаблонов: %w", err)
+	}
+
+	var opts []policy.TemplateOption
+	if *strict {
+		opts = append(opts, policy.StrictTemplates())
+	}
+
+	registry, err := policy.LoadTemplates(*templates, opts...)
+	if err != nil {
+		return err
+	}
+
+	modules, err := registry.Render(data)
+	if err != nil {
+		return err
+	}
+
+	for _, module := range modules {
+		if *outDir == "" {
+			fmt.Println(color.New(color.Faint).Sprintf("# %s", module.Name))
+			fmt.Println(module.Source)
+			continue
+		}
+
+		if err = os.MkdirAll(*outDir, 0o755); err != nil {
+			return err
+		}
+
+		path := filepath.Join(*outDir, module.Name)
+		if err = os.WriteFile(path, []byte(module.Source), 0o644); err != nil {
+			return err
+		}
+
+		fmt.Println(color.GreenString("Записана политика %s", path))
+	}
+
+	return nil
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/fatih/color"
+
+	"github.com/olezhek28/access_policy/pkg/policy"
+)
+
+func runTest(ctx context.Context, args []string) error {
+	fs := newFlagSet("test", "[пути...]",
+		"Находит файлы *_test.rego, загружает их вместе с политиками и данными и выполняет правила test_*.\n"+
+			"Пример: policyctl test -coverage cmd/4_complex_policy")
+
+	verbose := fs.Bool("v", false, "выводить пройденные тесты, вывод print() и трассировку упавших тестов")
+	coverage := fs.Bool("coverage", false, "выводить покрытие политик тестами по файлам")
+	threshold := fs.Float64("threshold", 0, "минимальное покрытие политик тестами в процентах")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	paths := pathsOrCurrent(fs)
+
+	report, err := policy.RunTests(ctx, paths...)
+	if err != nil {
+		return err
+	}
+
+	if len(report.Results) == 0 {
+		fmt.Println(color.YellowString("Тесты политик не найдены в %s", strings.Join(paths, ", ")))
+		return nil
+	}
+
+	var passed, failed, skipped int
+	for _, res := range report.Results {
+		name := res.Package + "." + res.Name
+
+		switch {
+		case res.Skip:
+			skipped++
+			if *verbose {
+				fmt.Printf("%s %s\n", color.YellowString("SKIP"), name)
+			}
+		case res.Err != nil:
+			failed++
+			fmt.Printf("%s %s (%s)\n  %v\n", color.RedString("ERROR"), name, res.Location, res.Err)
+		case res.Fail:
+			failed++
+			fmt.Printf("%s %s (%s)\n", color.RedString("FAIL"), name, res.Location)
+			if res.FailedAt != "" {
+				fmt.Printf("  не выполнилось: %s\n", res.FailedAt)
+			}
+		default:
+			passed++
+			if *verbose {
+				fmt.Printf("%s %s (%s)\n", color.GreenString("PASS"), name, res.Duration)
+			}
+		}
+
+		if *verbose && res.Output != "" {
+			fmt.Printf("  вывод print():\n%s", indent(res.Output, "    "))
+		}
+		if *verbose && res.Trace != "" {
+			fmt.Printf("  трассировка:\n%s", indent(res.Trace, "    "))
+		}
+	}
+
+	if *coverage {
+		files := make([]string, 0, len(report.Coverage.Files))
+		for file := range report.Coverage.Files {
+			files = append(files, file)
+		}
+		sort.Strings(files)
+
+		fmt.Println()
+		for _, file := range files {
+			fmt.Printf("покрытие %s: %.1f%%\n", file, report.Coverage.Files[file].Coverage)
+		}
+	}
+
+	fmt.Println()
+	summary := fmt.Sprintf("Пройдено: %d, не пройдено: %d, пропущено: %d, покрытие: %.1f%%",
+		passed, failed, skipped, report.Coverage.Coverage)
+
+	if failed > 0 {
+		fmt.Println(color.RedString(summary))
+		return errFailed
+	}
+
+	if *threshold > 0 && report.Coverage.Coverage < *threshold {
+		fmt.Println(color.RedString("%s, ниже порога %.1f%%", summary, *threshold))
+		return errFailed
+	}
+
+	fmt.Println(color.GreenString(summary))
+
+	return nil
+}
+
+// indent добавляет отступ к каждой строке текста
+func indent(text, prefix string) string {
+	lines := strings.SplitAfter(strings.TrimRight(text, "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = prefix + line
+	}
+
+	return strings.Join(lines, "") + "\n"
+}
@@ -0,0 +1,166 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: authz/v1/authz.proto
+
+package authzv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	AuthzService_Check_FullMethodName       = "/authz.v1.AuthzService/Check"
+	AuthzService_CheckStream_FullMethodName = "/authz.v1.AuthzService/CheckStream"
+)
+
+// AuthzServiceClient is the client API for AuthzService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// AuthzService проверка доступа к ресурсу по политике final_check
+type AuthzServiceClient interface {
+	// Check проверяет доступ для одного запроса
+	Check(ctx context.Context, in *CheckRequest, opts ...grpc.CallOption) (*CheckResponse, error)
+	// CheckStream проверяет доступ для потока запросов.
+	// Ответы приходят в том же порядке, что и запросы, и содержат request_id запроса.
+	// Ошибка проверки одного запроса возвращается в поле error его ответа и не прерывает поток.
+	CheckStream(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[CheckRequest, CheckResponse], error)
+}
+
+type authzServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewAuthzServiceClient(cc grpc.ClientConnInterface) AuthzServiceClient {
+	return &authzServiceClient{cc}
+}
+
+func (c *authzServiceClient) Check(ctx context.Context, in *CheckRequest, opts ...grpc.CallOption) (*CheckResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CheckResponse)
+	err := c.cc.Invoke(ctx, AuthzService_Check_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *authzServiceClient) CheckStream(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[CheckRequest, CheckResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &AuthzService_ServiceDesc.Streams[0], AuthzService_CheckStream_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[CheckRequest, CheckResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type AuthzService_CheckStreamClient = grpc.BidiStreamingClient[CheckRequest, CheckResponse]
+
+// AuthzServiceServer is the server API for AuthzService service.
+// All implementations must embed UnimplementedAuthzServiceServer
+// for forward compatibility.
+//
+// AuthzService проверка доступа к ресурсу по политике final_check
+type AuthzServiceServer interface {
+	// Check проверяет доступ для одного запроса
+	Check(context.Context, *CheckRequest) (*CheckResponse, error)
+	// CheckStream проверяет доступ для потока запросов.
+	// Ответы приходят в том же порядке, что и запросы, и содержат request_id запроса.
+	// Ошибка проверки одного запроса возвращается в поле error его ответа и не прерывает поток.
+	CheckStream(grpc.BidiStreamingServer[CheckRequest, CheckResponse]) error
+	mustEmbedUnimplementedAuthzServiceServer()
+}
+
+// UnimplementedAuthzServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedAuthzServiceServer struct{}
+
+func (UnimplementedAuthzServiceServer) Check(context.Context, *CheckRequest) (*CheckResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Check not implemented")
+}
+func (UnimplementedAuthzServiceServer) CheckStream(grpc.BidiStreamingServer[CheckRequest, CheckResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method CheckStream not implemented")
+}
+func (UnimplementedAuthzServiceServer) mustEmbedUnimplementedAuthzServiceServer() {}
+func (UnimplementedAuthzServiceServer) testEmbeddedByValue()                      {}
+
+// UnsafeAuthzServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to AuthzServiceServer will
+// result in compilation errors.
+type UnsafeAuthzServiceServer interface {
+	mustEmbedUnimplementedAuthzServiceServer()
+}
+
+func RegisterAuthzServiceServer(s grpc.ServiceRegistrar, srv AuthzServiceServer) {
+	// If the following call pancis, it indicates UnimplementedAuthzServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&AuthzService_ServiceDesc, srv)
+}
+
+func _AuthzService_Check_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CheckRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthzServiceServer).Check(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AuthzService_Check_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthzServiceServer).Check(ctx, req.(*CheckRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AuthzService_CheckStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(AuthzServiceServer).CheckStream(&grpc.GenericServerStream[CheckRequest, CheckResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type AuthzService_CheckStreamServer = grpc.BidiStreamingServer[CheckRequest, CheckResponse]
+
+// AuthzService_ServiceDesc is the grpc.ServiceDesc for AuthzService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var AuthzService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "authz.v1.AuthzService",
+	HandlerType: (*AuthzServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Check",
+			Handler:    _AuthzService_Check_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "CheckStream",
+			Handler:       _AuthzService_CheckStream_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "authz/v1/authz.proto",
+}
@@ -0,0 +1,7 @@
+// Package authzv1 сгенерированный код gRPC-API проверки доступа.
+//
+// Код генерируется из api/authz/v1/authz.proto:
+//
+//	protoc -I api --go_out=pkg/api --go_opt=paths=source_relative \
+//		--go-grpc_out=pkg/api --go-grpc_opt=paths=source_relative authz/v1/authz.proto
+package authzv1
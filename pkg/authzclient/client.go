@@ -0,0 +1,85 @@
+// Package authzclient клиент gRPC-сервиса проверки доступа из cmd/policy-server
+package authzclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"google.golang.org/grpc"
+
+	authzv1 "github.com/olezhek28/access_policy/pkg/api/authz/v1"
+)
+
+// Client клиент AuthzService
+type Client struct {
+	api authzv1.AuthzServiceClient
+}
+
+// New создает клиента поверх уже установленного соединения
+func New(conn grpc.ClientConnInterface) *Client {
+	return &Client{
+		api: authzv1.NewAuthzServiceClient(conn),
+	}
+}
+
+// Check проверяет доступ для одного запроса
+func (c *Client) Check(ctx context.Context, req *authzv1.CheckRequest, opts ...grpc.CallOption) (*authzv1.CheckResponse, error) {
+	resp, err := c.api.Check(ctx, req, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при проверке доступа: %w", err)
+	}
+
+	return resp, nil
+}
+
+// CheckBatch проверяет доступ для набора запросов через один поток.
+// Ответы возвращаются в том же порядке, что и запросы. Ошибка проверки отдельного запроса
+// (например, некорректные входные данные) возвращается в поле Error его ответа, а не ошибкой CheckBatch.
+func (c *Client) CheckBatch(ctx context.Context, reqs []*authzv1.CheckRequest, opts ...grpc.CallOption) ([]*authzv1.CheckResponse, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stream, err := c.api.CheckStream(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при открытии потока проверок: %w", err)
+	}
+
+	// Запросы отправляются параллельно с чтением ответов, чтобы большой пакет
+	// не упирался в буферы потока
+	sendErr := make(chan error, 1)
+	go func() {
+		for _, req := range reqs {
+			if err := stream.Send(req); err != nil {
+				sendErr <- err
+				return
+			}
+		}
+		sendErr <- stream.CloseSend()
+	}()
+
+	resps := make([]*authzv1.CheckResponse, 0, len(reqs))
+	for {
+		resp, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("ошибка при получении результата проверки: %w", err)
+		}
+
+		resps = append(resps, resp)
+	}
+
+	// Ошибка отправки io.EOF означает, что сервер закрыл поток, и настоящая ошибка уже получена через Recv
+	if err = <-sendErr; err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("ошибка при отправке запроса на проверку: %w", err)
+	}
+
+	if len(resps) != len(reqs) {
+		return nil, fmt.Errorf("получено %d результатов проверки вместо %d", len(resps), len(reqs))
+	}
+
+	return resps, nil
+}
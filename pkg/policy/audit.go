@@ -0,0 +1,162 @@
+package policy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// DecisionRecord запись журнала решений: какие входные данные привели к какому результату
+type DecisionRecord struct {
+	DecisionID string    `json:"decision_id"`
+	Timestamp  time.Time `json:"timestamp"`
+	Query      string    `json:"query"`
+	// Input входные данные после маскирования
+	Input interface{} `json:"input,omitempty"`
+	// Result результат запроса после маскирования. Отсутствует, если правило не определено.
+	Result interface{} `json:"result,omitempty"`
+	// Revision ревизия политик и данных, по которым вычислено решение
+	Revision string        `json:"revision,omitempty"`
+	Duration time.Duration `json:"duration_ns"`
+	// Error ошибка компиляции или вычисления политики
+	Error string `json:"error,omitempty"`
+	// Erased пути полей, удаленных правилами маскирования
+	Erased []string `json:"erased,omitempty"`
+}
+
+// DecisionSink получатель записей журнала решений.
+// Log вызывается синхронно после каждого вычисления, поэтому медленные получатели должны буферизовать записи сами.
+type DecisionSink interface {
+	Log(ctx context.Context, record DecisionRecord) error
+}
+
+// DecisionSinkFunc позволяет использовать функцию как DecisionSink
+type DecisionSinkFunc func(ctx context.Context, record DecisionRecord) error
+
+// Log вызывает f(ctx, record)
+func (f DecisionSinkFunc) Log(ctx context.Context, record DecisionRecord) error {
+	return f(ctx, record)
+}
+
+// WithDecisionLog подключает получателя журнала решений. Каждое вычисление Eval записывается
+// во все подключенные получатели. Ошибка получателя пишется в лог и не влияет на решение.
+func WithDecisionLog(sink DecisionSink) Option {
+	return func(e *Engine) {
+		e.sinks = append(e.sinks, sink)
+	}
+}
+
+// WithDecisionMask задает правила маскирования: поля по путям paths удаляются из записи
+// до передачи получателям журнала решений. Путь начинается с input или result,
+// например input.source_uuid. Если на пути встречается массив, правило применяется к каждому элементу.
+func WithDecisionMask(paths ...string) Option {
+	return func(e *Engine) {
+		for _, path := range paths {
+			e.masks = append(e.masks, strings.Split(path, "."))
+		}
+	}
+}
+
+// logDecision записывает решение во все получатели журнала
+func (e *Engine) logDecision(ctx context.Context, start time.Time, input interface{}, decision Decision, evalErr error) {
+	record := DecisionRecord{
+		DecisionID: decision.ID,
+		Timestamp:  start,
+		Query:      decision.Query,
+		Revision:   decision.Revision,
+		Duration:   time.Since(start),
+	}
+	if evalErr != nil {
+		record.Error = evalErr.Error()
+	}
+
+	// Маскирование меняет документ, поэтому входные данные и результат копируются,
+	// чтобы не затронуть ни данные вызывающего кода, ни возвращаемое решение
+	doc := make(map[string]interface{}, 2)
+	if input != nil {
+		doc["input"] = input
+	}
+	if decision.Defined {
+		doc["result"] = decision.Value
+	}
+
+	// Если документ не сериализуется, запись уходит без входных данных и результата,
+	// чтобы немаскированные данные не попали в журнал
+	masked, err := copyDocument(doc)
+	if err != nil {
+		slog.Error("ошибка подготовки записи журнала решений",
+			slog.String("decision_id", record.DecisionID),
+			slog.Any("error", err),
+		)
+	} else {
+		for _, path := range e.masks {
+			if erase(masked, path) {
+				record.Erased = append(record.Erased, strings.Join(path, "."))
+			}
+		}
+
+		record.Input = masked["input"]
+		record.Result = masked["result"]
+	}
+
+	for _, sink := range e.sinks {
+		if err = sink.Log(ctx, record); err != nil {
+			slog.Error("ошибка записи решения в журнал",
+				slog.String("decision_id", record.DecisionID),
+				slog.Any("error", err),
+			)
+		}
+	}
+}
+
+// copyDocument делает глубокую копию документа в виде JSON-значений.
+// Числа сохраняются как json.Number, так же как их видит OPA.
+func copyDocument(doc map[string]interface{}) (map[string]interface{}, error) {
+	raw, err := json.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка сериализации решения для журнала: %w", err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+
+	var res map[string]interface{}
+	if err = decoder.Decode(&res); err != nil {
+		return nil, fmt.Errorf("ошибка сериализации решения для журнала: %w", err)
+	}
+
+	return res, nil
+}
+
+// erase удаляет поле по пути path и сообщает, было ли что-то удалено
+func erase(node interface{}, path []string) bool {
+	switch v := node.(type) {
+	case map[string]interface{}:
+		next, ok := v[path[0]]
+		if !ok {
+			return false
+		}
+
+		if len(path) == 1 {
+			delete(v, path[0])
+			return true
+		}
+
+		return erase(next, path[1:])
+	case []interface{}:
+		erased := false
+		for _, item := range v {
+			if erase(item, path) {
+				erased = true
+			}
+		}
+
+		return erased
+	default:
+		return false
+	}
+}
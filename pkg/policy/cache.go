@@ -0,0 +1,252 @@
+package policy
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+const defaultCacheSize = 1000
+
+// EngineCache хранит скомпилированные движки для политик, сгенерированных из шаблонов,
+// по одному набору политик на ресурс. Ключ кеша - хеш данных шаблона (например PolicyData ресурса).
+// Когда кеш заполнен, вытесняется движок, который дольше всех не запрашивался (LRU).
+// Конкурентные первые запросы для одного ресурса компилируют политики один раз. EngineCache безопасен для конкурентного использования.
+type EngineCache struct {
+	templates  *TemplateRegistry
+	query      string
+	size       int
+	ttl        time.Duration
+	engineOpts []Option
+
+	mu      sync.Mutex
+	lru     *list.List
+	entries map[string]*list.Element
+	stats   CacheStats
+
+	group singleflight.Group
+}
+
+type cacheEntry struct {
+	key      string
+	engine   *Engine
+	compiled time.Time
+}
+
+// CacheStats счетчики кеша движков
+type CacheStats struct {
+	// Size число движков в кеше
+	Size int
+	// Hits запросы, для которых движок уже был в кеше
+	Hits uint64
+	// Misses запросы, которые не нашли движок в кеше
+	Misses uint64
+	// Compilations число компиляций политик, в том числе неудачных. Конкурентные промахи по одному ресурсу
+	// компилируют политики один раз, поэтому Compilations может быть меньше Misses.
+	// Ошибки генерации политик из шаблонов до компиляции не доходят и не учитываются.
+	Compilations uint64
+	// Evictions движки, вытесненные из-за ограничения размера
+	Evictions uint64
+	// Expirations движки, удаленные по истечении TTL
+	Expirations uint64
+}
+
+// CacheOption настраивает EngineCache
+type CacheOption func(c *EngineCache)
+
+// WithCacheSize задает максимальное число движков в кеше. По-умолчанию 1000.
+func WithCacheSize(size int) CacheOption {
+	return func(c *EngineCache) {
+		c.size = size
+	}
+}
+
+// WithCacheTTL задает время жизни движка, после которого политики генерируются и компилируются заново.
+// По-умолчанию движки не устаревают.
+func WithCacheTTL(ttl time.Duration) CacheOption {
+	return func(c *EngineCache) {
+		c.ttl = ttl
+	}
+}
+
+// WithEngineOptions задает опции, с которыми создается каждый движок, например WithPrintLogger или WithDecisionLog
+func WithEngineOptions(opts ...Option) CacheOption {
+	return func(c *EngineCache) {
+		c.engineOpts = append(c.engineOpts, opts...)
+	}
+}
+
+// NewEngineCache создает кеш движков для запроса query к политикам из шаблонов templates
+func NewEngineCache(templates *TemplateRegistry, query string, opts ...CacheOption) *EngineCache {
+	c := &EngineCache{
+		templates: templates,
+		query:     query,
+		size:      defaultCacheSize,
+		lru:       list.New(),
+		entries:   make(map[string]*list.Element),
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+// Engine возвращает скомпилированный движок для политик, сгенерированных с данными data.
+// Если движка нет в кеше или он устарел, политики генерируются, проверяются и компилируются.
+// Ошибки генерации и компиляции не кешируются.
+func (c *EngineCache) Engine(ctx context.Context, data interface{}) (*Engine, error) {
+	key, err := cacheKey(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if engine, ok := c.get(key); ok {
+		return engine, nil
+	}
+
+	res, err, _ := c.group.Do(key, func() (interface{}, error) {
+		// Пока ждали, движок мог положить в кеш предыдущий вызов с тем же ключом
+		if engine, ok := c.peek(key); ok {
+			return engine, nil
+		}
+
+		// Результат компиляции получают все ожидающие вызовы с тем же ключом, поэтому отмена контекста
+		// первого вызова не должна прерывать компиляцию для остальных
+		engine, err := c.compile(context.WithoutCancel(ctx), data)
+		if err != nil {
+			return nil, err
+		}
+
+		c.add(key, engine)
+
+		return engine, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return res.(*Engine), nil
+}
+
+// Stats возвращает текущие счетчики кеша
+func (c *EngineCache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.stats
+	stats.Size = c.lru.Len()
+
+	return stats
+}
+
+// Purge удаляет все движки из кеша, например после изменения шаблонов
+func (c *EngineCache) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.lru.Init()
+	c.entries = make(map[string]*list.Element)
+}
+
+func (c *EngineCache) compile(ctx context.Context, data interface{}) (*Engine, error) {
+	modules, err := c.templates.Render(data)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.stats.Compilations++
+	c.mu.Unlock()
+
+	opts := append([]Option{WithModules(modules...)}, c.engineOpts...)
+	engine := New(c.query, opts...)
+	if err = engine.Prepare(ctx); err != nil {
+		return nil, err
+	}
+
+	return engine, nil
+}
+
+// get ищет движок и учитывает обращение в счетчиках
+func (c *EngineCache) get(key string) (*Engine, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	engine, ok := c.lookup(key)
+	if ok {
+		c.stats.Hits++
+	} else {
+		c.stats.Misses++
+	}
+
+	return engine, ok
+}
+
+// peek ищет движок без учета в счетчиках попаданий и промахов
+func (c *EngineCache) peek(key string) (*Engine, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.lookup(key)
+}
+
+func (c *EngineCache) lookup(key string) (*Engine, bool) {
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	entry := elem.Value.(*cacheEntry)
+	if c.ttl > 0 && time.Since(entry.compiled) > c.ttl {
+		c.remove(elem)
+		c.stats.Expirations++
+		return nil, false
+	}
+
+	c.lru.MoveToFront(elem)
+
+	return entry.engine, true
+}
+
+func (c *EngineCache) add(key string, engine *Engine) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[key] = c.lru.PushFront(&cacheEntry{
+		key:      key,
+		engine:   engine,
+		compiled: time.Now(),
+	})
+
+	for c.size > 0 && c.lru.Len() > c.size {
+		c.remove(c.lru.Back())
+		c.stats.Evictions++
+	}
+}
+
+func (c *EngineCache) remove(elem *list.Element) {
+	c.lru.Remove(elem)
+	delete(c.entries, elem.Value.(*cacheEntry).key)
+}
+
+// cacheKey хеш данных шаблона. Поля структур сериализуются в порядке объявления, а ключи map - в отсортированном,
+// поэтому одинаковые данные всегда дают одинаковый ключ.
+func cacheKey(data interface{}) (string, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return "", fmt.Errorf("ошибка вычисления ключа кеша: %w", err)
+	}
+
+	sum := sha256.Sum256(raw)
+
+	return hex.EncodeToString(sum[:]), nil
+}
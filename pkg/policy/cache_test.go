@@ -0,0 +1,208 @@
+package policy
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// resourceData данные шаблона ресурса для тестов кеша
+type resourceData struct {
+	Slug string
+}
+
+func newTestCache(t *testing.T, opts ...CacheOption) *EngineCache {
+	t.Helper()
+
+	templates, err := LoadTemplatesFS(fstest.MapFS{
+		"resource.tmpl": {Data: []byte(`package resource
+
+default allow = false
+
+allow {
+	input.slug == {{ regoString .Slug }}
+}
+`)},
+	}, StrictTemplates())
+	if err != nil {
+		t.Fatalf("LoadTemplatesFS: %v", err)
+	}
+
+	return NewEngineCache(templates, "data.resource.allow", opts...)
+}
+
+func TestEngineCacheSingleflight(t *testing.T) {
+	cache := newTestCache(t)
+
+	const goroutines = 32
+
+	var wg sync.WaitGroup
+	engines := make([]*Engine, goroutines)
+	errs := make([]error, goroutines)
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			engines[i], errs[i] = cache.Engine(context.Background(), resourceData{Slug: "a"})
+		}(i)
+	}
+	wg.Wait()
+
+	for i := range engines {
+		if errs[i] != nil {
+			t.Fatalf("Engine: %v", errs[i])
+		}
+		if engines[i] != engines[0] {
+			t.Fatal("конкурентные вызовы получили разные движки для одних данных")
+		}
+	}
+
+	stats := cache.Stats()
+	if stats.Compilations != 1 {
+		t.Errorf("компиляций %d, ожидалась 1", stats.Compilations)
+	}
+	if stats.Hits+stats.Misses != goroutines || stats.Size != 1 {
+		t.Errorf("счетчики %+v", stats)
+	}
+
+	decision, err := engines[0].Eval(context.Background(), map[string]interface{}{"slug": "a"})
+	if err != nil {
+		t.Fatalf("Eval: %v", err)
+	}
+	if allowed, _ := decision.Bool(); !allowed {
+		t.Error("движок из кеша не использует данные шаблона")
+	}
+}
+
+// TestEngineCacheCanceledCaller проверяет, что отмена контекста одного вызова не влияет на остальных,
+// которые ждут ту же компиляцию
+func TestEngineCacheCanceledCaller(t *testing.T) {
+	cache := newTestCache(t)
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var wg sync.WaitGroup
+	errs := make([]error, 8)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			ctx := context.Background()
+			if i == 0 {
+				ctx = canceled
+			}
+			_, errs[i] = cache.Engine(ctx, resourceData{Slug: "a"})
+		}(i)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("вызов %d: %v", i, err)
+		}
+	}
+}
+
+func TestEngineCacheEviction(t *testing.T) {
+	ctx := context.Background()
+	cache := newTestCache(t, WithCacheSize(2))
+
+	a, err := cache.Engine(ctx, resourceData{Slug: "a"})
+	if err != nil {
+		t.Fatalf("Engine: %v", err)
+	}
+	if _, err = cache.Engine(ctx, resourceData{Slug: "b"}); err != nil {
+		t.Fatalf("Engine: %v", err)
+	}
+
+	// Обращение к a делает вытесняемым b, который дольше всех не запрашивался
+	if got, _ := cache.Engine(ctx, resourceData{Slug: "a"}); got != a {
+		t.Fatal("движок a не найден в кеше")
+	}
+	if _, err = cache.Engine(ctx, resourceData{Slug: "c"}); err != nil {
+		t.Fatalf("Engine: %v", err)
+	}
+
+	if got, _ := cache.Engine(ctx, resourceData{Slug: "a"}); got != a {
+		t.Error("вытеснен недавно запрошенный движок a")
+	}
+
+	want := CacheStats{Size: 2, Hits: 2, Misses: 3, Compilations: 3, Evictions: 1}
+	if stats := cache.Stats(); stats != want {
+		t.Errorf("счетчики %+v, ожидались %+v", stats, want)
+	}
+
+	// b был вытеснен, поэтому компилируется заново и вытесняет c
+	if _, err = cache.Engine(ctx, resourceData{Slug: "b"}); err != nil {
+		t.Fatalf("Engine: %v", err)
+	}
+	if stats := cache.Stats(); stats.Compilations != 4 || stats.Evictions != 2 {
+		t.Errorf("счетчики %+v после повторного запроса b", stats)
+	}
+}
+
+func TestEngineCacheExpiration(t *testing.T) {
+	ctx := context.Background()
+	cache := newTestCache(t, WithCacheTTL(50*time.Millisecond))
+
+	first, err := cache.Engine(ctx, resourceData{Slug: "a"})
+	if err != nil {
+		t.Fatalf("Engine: %v", err)
+	}
+	if got, _ := cache.Engine(ctx, resourceData{Slug: "a"}); got != first {
+		t.Fatal("движок устарел раньше TTL")
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	second, err := cache.Engine(ctx, resourceData{Slug: "a"})
+	if err != nil {
+		t.Fatalf("Engine: %v", err)
+	}
+	if second == first {
+		t.Error("устаревший движок не скомпилирован заново")
+	}
+
+	want := CacheStats{Size: 1, Hits: 1, Misses: 2, Compilations: 2, Expirations: 1}
+	if stats := cache.Stats(); stats != want {
+		t.Errorf("счетчики %+v, ожидались %+v", stats, want)
+	}
+}
+
+func TestEngineCacheErrorNotCached(t *testing.T) {
+	ctx := context.Background()
+	cache := newTestCache(t)
+
+	// Данные без поля Slug не генерируют политику
+	for i := 0; i < 2; i++ {
+		if _, err := cache.Engine(ctx, map[string]interface{}{}); err == nil {
+			t.Fatal("ожидалась ошибка генерации политики")
+		}
+	}
+
+	if stats := cache.Stats(); stats.Size != 0 || stats.Compilations != 0 || stats.Misses != 2 {
+		t.Errorf("счетчики %+v, ошибка не должна попадать в кеш", stats)
+	}
+}
+
+// TestEngineCacheCompileErrorCounted проверяет, что неудачная компиляция учитывается в Compilations, но не кешируется
+func TestEngineCacheCompileErrorCounted(t *testing.T) {
+	ctx := context.Background()
+	// Схема входных данных не существует, поэтому сгенерированные политики не компилируются
+	cache := newTestCache(t, WithEngineOptions(WithInputSchema("no_such_schema.json")))
+
+	for i := 0; i < 2; i++ {
+		if _, err := cache.Engine(ctx, resourceData{Slug: "a"}); err == nil {
+			t.Fatal("ожидалась ошибка компиляции")
+		}
+	}
+
+	want := CacheStats{Misses: 2, Compilations: 2}
+	if stats := cache.Stats(); stats != want {
+		t.Errorf("счетчики %+v, ожидались %+v", stats, want)
+	}
+}
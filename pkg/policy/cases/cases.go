@@ -0,0 +1,93 @@
+// Package cases описывает проверки политик в файлах кейсов (YAML или JSON) и выполняет их.
+//
+// Файл кейсов содержит список кейсов с входными данными и ожидаемыми полями результата:
+//
+//	cases:
+//	  - name: Доступ запрещен, недостаточно прав
+//	    input:
+//	      action: update
+//	      source_uuid: 0FF8AFB4-55D2-4836-B17C-643AD59BBB2F
+//	      source_slug: some_slug
+//	      user_permissions: [read]
+//	    expect:
+//	      access_allowed: false
+//	      missing_permissions: [write]
+//	  - name: Некорректные входные данные
+//	    input:
+//	      action: update
+//	    expect_input_error: true
+//
+// В expect перечисляются только поля, которые нужно проверить: остальные поля результата не сравниваются.
+// Кейсы выполняются из кода (Run), из go test (policytest.RunCases) и из командной строки (policyctl cases).
+package cases
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/open-policy-agent/opa/util"
+)
+
+// Case кейс проверки политики. T - тип входных данных, например сгенерированный по схеме AccessRequest
+// или interface{} для входных данных произвольной формы.
+type Case[T any] struct {
+	// Name название кейса
+	Name string `json:"name"`
+	// Input входные данные политики
+	Input T `json:"input"`
+	// Expect ожидаемые поля результата. Объекты сравниваются по перечисленным полям, массивы и значения - целиком.
+	Expect map[string]interface{} `json:"expect,omitempty"`
+	// ExpectInputError входные данные не должны пройти проверку по схеме (policy.WithInputSchema)
+	ExpectInputError bool `json:"expect_input_error,omitempty"`
+}
+
+// file формат файла кейсов
+type file[T any] struct {
+	Cases []Case[T] `json:"cases"`
+}
+
+// Load загружает кейсы из YAML- или JSON-файлов в порядке путей.
+// Входные данные декодируются в T, и поле, которого нет в T, - ошибка загрузки,
+// поэтому опечатка в имени поля файла кейсов не проходит незамеченной.
+func Load[T any](paths ...string) ([]Case[T], error) {
+	var cases []Case[T]
+
+	for _, path := range paths {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка загрузки кейсов: %w", err)
+		}
+
+		// util.Unmarshal разбирает и JSON, и YAML, а числа сохраняет как json.Number
+		var doc interface{}
+		if err = util.Unmarshal(raw, &doc); err != nil {
+			return nil, fmt.Errorf("ошибка разбора кейсов из %s: %w", path, err)
+		}
+
+		normalized, err := json.Marshal(doc)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка разбора кейсов из %s: %w", path, err)
+		}
+
+		decoder := json.NewDecoder(bytes.NewReader(normalized))
+		decoder.UseNumber()
+		decoder.DisallowUnknownFields()
+
+		var f file[T]
+		if err = decoder.Decode(&f); err != nil {
+			return nil, fmt.Errorf("ошибка разбора кейсов из %s: %w", path, err)
+		}
+
+		for i, c := range f.Cases {
+			if c.Name == "" {
+				return nil, fmt.Errorf("%s: у кейса %d нет названия", path, i+1)
+			}
+		}
+
+		cases = append(cases, f.Cases...)
+	}
+
+	return cases, nil
+}
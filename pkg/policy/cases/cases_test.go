@@ -0,0 +1,234 @@
+package cases
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/olezhek28/access_policy/pkg/policy"
+)
+
+// request входные данные тестовых кейсов
+type request struct {
+	Role  string `json:"role"`
+	Level int    `json:"level,omitempty"`
+}
+
+const casesYAML = `cases:
+  - name: admin
+    input:
+      role: admin
+      level: 3
+    expect:
+      allow: true
+      roles: [admin]
+  - name: некорректная роль
+    input:
+      role: ""
+    expect_input_error: true
+`
+
+const casesJSON = `{
+    "cases": [
+        {"name": "admin", "input": {"role": "admin", "level": 3}, "expect": {"allow": true, "roles": ["admin"]}},
+        {"name": "некорректная роль", "input": {"role": ""}, "expect_input_error": true}
+    ]
+}`
+
+func writeCases(t *testing.T, name, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	fromYAML, err := Load[request](writeCases(t, "cases.yaml", casesYAML))
+	if err != nil {
+		t.Fatalf("Load YAML: %v", err)
+	}
+	fromJSON, err := Load[request](writeCases(t, "cases.json", casesJSON))
+	if err != nil {
+		t.Fatalf("Load JSON: %v", err)
+	}
+
+	if !reflect.DeepEqual(fromYAML, fromJSON) {
+		t.Errorf("кейсы из YAML и JSON различаются:\n%+v\n%+v", fromYAML, fromJSON)
+	}
+
+	want := Case[request]{
+		Name:   "admin",
+		Input:  request{Role: "admin", Level: 3},
+		Expect: map[string]interface{}{"allow": true, "roles": []interface{}{"admin"}},
+	}
+	if len(fromYAML) != 2 || !reflect.DeepEqual(fromYAML[0], want) || !fromYAML[1].ExpectInputError {
+		t.Errorf("кейсы %+v", fromYAML)
+	}
+
+	// Кейсы из нескольких файлов загружаются в порядке путей
+	both, err := Load[request](writeCases(t, "a.yaml", casesYAML), writeCases(t, "b.json", casesJSON))
+	if err != nil || len(both) != 4 {
+		t.Errorf("из двух файлов загружено %d кейсов: %v", len(both), err)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{name: "опечатка в поле входных данных", content: "cases:\n  - name: a\n    input:\n      rle: admin\n", want: `unknown field "rle"`},
+		{name: "опечатка в поле кейса", content: "cases:\n  - name: a\n    expected:\n      allow: true\n", want: `unknown field "expected"`},
+		{name: "кейс без названия", content: "cases:\n  - input:\n      role: admin\n", want: "у кейса 1 нет названия"},
+		{name: "неверный тип", content: "cases:\n  - name: a\n    input:\n      level: high\n", want: "ошибка разбора кейсов"},
+		{name: "некорректный YAML", content: "cases: [\n", want: "ошибка разбора кейсов"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load[request](writeCases(t, "cases.yaml", tt.content))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("ошибка %v, ожидалась %q", err, tt.want)
+			}
+		})
+	}
+
+	if _, err := Load[request](filepath.Join(t.TempDir(), "no_such.yaml")); err == nil {
+		t.Error("несуществующий файл загружен без ошибки")
+	}
+}
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected string
+		actual   string
+		want     []string
+	}{
+		{
+			name:     "сравниваются только ожидаемые поля",
+			expected: `{"allow": true}`,
+			actual:   `{"allow": true, "roles": ["admin"]}`,
+		},
+		{
+			name:     "числа сравниваются по значению",
+			expected: `{"level": 5}`,
+			actual:   `{"level": 5.0}`,
+		},
+		{
+			name:     "отличается значение",
+			expected: `{"allow": true, "level": 1}`,
+			actual:   `{"allow": false, "level": 2}`,
+			want:     []string{"result.allow: ожидалось true, получено false", "result.level: ожидалось 1, получено 2"},
+		},
+		{
+			name:     "поля нет в результате",
+			expected: `{"missing_permissions": []}`,
+			actual:   `{"allow": false}`,
+			want:     []string{"result.missing_permissions: ожидалось [], поля нет в результате"},
+		},
+		{
+			name:     "массив другой длины",
+			expected: `{"roles": ["admin"]}`,
+			actual:   `{"roles": ["admin", "manager"]}`,
+			want:     []string{`result.roles: ожидалось ["admin"], получено ["admin","manager"]`},
+		},
+		{
+			name:     "вложенный объект в массиве",
+			expected: `{"violations": [{"code": "permission_missing"}]}`,
+			actual:   `{"violations": [{"code": "action_unknown", "field": "action"}]}`,
+			want:     []string{`result.violations[0].code: ожидалось "permission_missing", получено "action_unknown"`},
+		},
+		{
+			name:     "значение другого типа",
+			expected: `{"roles": {"admin": true}}`,
+			actual:   `{"roles": null}`,
+			want:     []string{`result.roles: ожидалось {"admin":true}, получено null`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, d := range diff("result", decodeJSON(t, tt.expected), decodeJSON(t, tt.actual)) {
+				got = append(got, d.String())
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("отличия %q, ожидались %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunReport(t *testing.T) {
+	schema := writeCases(t, "input.json", `{"type": "object", "properties": {"role": {"type": "string", "minLength": 1}}}`)
+	engine := policy.New("data.authz.result",
+		policy.WithModule("authz.rego", `package authz
+
+result := {"allow": input.role == "admin", "roles": [input.role]}
+`),
+		policy.WithInputSchema(schema),
+	)
+
+	report := Run(context.Background(), engine, []Case[request]{
+		{Name: "pass", Input: request{Role: "admin"}, Expect: map[string]interface{}{"allow": true}},
+		{Name: "diff", Input: request{Role: "manager"}, Expect: map[string]interface{}{"allow": true}},
+		{Name: "input error", Input: request{}, ExpectInputError: true},
+		{Name: "unexpected input error", Input: request{}, Expect: map[string]interface{}{"allow": false}},
+		{Name: "expected input error", Input: request{Role: "admin"}, ExpectInputError: true},
+	})
+
+	pass := make([]bool, len(report.Results))
+	for i, res := range report.Results {
+		pass[i] = res.Pass()
+	}
+	if want := []bool{true, false, true, false, false}; !reflect.DeepEqual(pass, want) {
+		t.Errorf("итоги кейсов %v, ожидались %v", pass, want)
+	}
+	if report.Results[3].InputError == nil {
+		t.Error("нет ошибки проверки входных данных у кейса unexpected input error")
+	}
+	if report.Passed() != 2 || report.Failed() != 3 {
+		t.Errorf("пройдено %d, не пройдено %d", report.Passed(), report.Failed())
+	}
+
+	var out bytes.Buffer
+	report.Print(&out)
+	for _, want := range []string{
+		"1  pass                    PASS",
+		"2  diff                    FAIL",
+		"2. diff\n  result.allow: ожидалось true, получено false",
+		"4. unexpected input error\n  ошибка: некорректные входные данные",
+		"5. expected input error\n  ошибка: ожидалась ошибка проверки входных данных",
+		"Пройдено: 2, не пройдено: 3",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("в отчете нет %q:\n%s", want, out.String())
+		}
+	}
+}
+
+func decodeJSON(t *testing.T, s string) interface{} {
+	t.Helper()
+
+	decoder := json.NewDecoder(strings.NewReader(s))
+	decoder.UseNumber()
+
+	var v interface{}
+	if err := decoder.Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+
+	return v
+}
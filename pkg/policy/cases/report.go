@@ -0,0 +1,48 @@
+package cases
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/fatih/color"
+)
+
+// Print выводит таблицу кейсов с итогом PASS/FAIL, отличия непройденных кейсов и сводку
+func (r Report) Print(w io.Writer) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "№\tКейс\tИтог")
+	for i, res := range r.Results {
+		status := color.GreenString("PASS")
+		if !res.Pass() {
+			status = color.RedString("FAIL")
+		}
+
+		fmt.Fprintf(tw, "%d\t%s\t%s\n", i+1, res.Name, status)
+	}
+	tw.Flush()
+
+	for i, res := range r.Results {
+		if res.Pass() {
+			continue
+		}
+
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, color.RedString("%d. %s", i+1, res.Name))
+		if res.Err != nil {
+			fmt.Fprintf(w, "  ошибка: %v\n", res.Err)
+		}
+		for _, d := range res.Diffs {
+			fmt.Fprintf(w, "  %s\n", d)
+		}
+	}
+
+	fmt.Fprintln(w)
+	summary := fmt.Sprintf("Пройдено: %d, не пройдено: %d", r.Passed(), r.Failed())
+	if r.Failed() > 0 {
+		fmt.Fprintln(w, color.RedString(summary))
+		return
+	}
+
+	fmt.Fprintln(w, color.GreenString(summary))
+}
@@ -0,0 +1,197 @@
+package cases
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/open-policy-agent/opa/util"
+
+	"github.com/olezhek28/access_policy/pkg/policy"
+)
+
+// Result результат выполнения кейса
+type Result struct {
+	// Name название кейса
+	Name string
+	// Decision решение политики. Для входных данных, не прошедших проверку по схеме, решение пустое.
+	Decision policy.Decision
+	// InputError ошибка проверки входных данных по схеме
+	InputError *policy.InputError
+	// Err ошибка вычисления, не связанная с входными данными
+	Err error
+	// Diffs отличия результата от ожидаемого
+	Diffs []Diff
+}
+
+// Pass сообщает, что кейс пройден
+func (r Result) Pass() bool {
+	return r.Err == nil && len(r.Diffs) == 0
+}
+
+// Diff отличие результата от ожидаемого
+type Diff struct {
+	// Path путь к полю результата, например result.missing_permissions
+	Path string
+	// Expected ожидаемое значение
+	Expected interface{}
+	// Actual фактическое значение. nil, если поля нет в результате (Missing).
+	Actual interface{}
+	// Missing в результате нет ожидаемого поля
+	Missing bool
+}
+
+func (d Diff) String() string {
+	if d.Missing {
+		return fmt.Sprintf("%s: ожидалось %s, поля нет в результате", d.Path, formatValue(d.Expected))
+	}
+
+	return fmt.Sprintf("%s: ожидалось %s, получено %s", d.Path, formatValue(d.Expected), formatValue(d.Actual))
+}
+
+// Report результаты кейсов в порядке их загрузки
+type Report struct {
+	Results []Result
+}
+
+// Passed число пройденных кейсов
+func (r Report) Passed() int {
+	passed := 0
+	for _, res := range r.Results {
+		if res.Pass() {
+			passed++
+		}
+	}
+
+	return passed
+}
+
+// Failed число непройденных кейсов
+func (r Report) Failed() int {
+	return len(r.Results) - r.Passed()
+}
+
+// Run вычисляет каждый кейс движком engine и сравнивает результат с ожидаемым
+func Run[T any](ctx context.Context, engine *policy.Engine, cases []Case[T]) Report {
+	report := Report{
+		Results: make([]Result, 0, len(cases)),
+	}
+
+	for _, c := range cases {
+		report.Results = append(report.Results, runCase(ctx, engine, c))
+	}
+
+	return report
+}
+
+func runCase[T any](ctx context.Context, engine *policy.Engine, c Case[T]) Result {
+	res := Result{Name: c.Name}
+
+	decision, err := engine.Eval(ctx, c.Input)
+	if errors.As(err, &res.InputError) {
+		if !c.ExpectInputError {
+			res.Err = res.InputError
+		}
+		return res
+	}
+	if err != nil {
+		res.Err = err
+		return res
+	}
+
+	res.Decision = decision
+
+	if c.ExpectInputError {
+		res.Err = errors.New("ожидалась ошибка проверки входных данных, но политика вычислена")
+		return res
+	}
+
+	if len(c.Expect) == 0 {
+		return res
+	}
+
+	if !decision.Defined {
+		res.Err = policy.ErrUndefined
+		return res
+	}
+
+	res.Diffs = diff("result", normalize(c.Expect), decision.Value)
+
+	return res
+}
+
+// diff сравнивает ожидаемое значение с фактическим. У объектов сравниваются только ожидаемые поля,
+// массивы и остальные значения сравниваются целиком.
+func diff(path string, expected, actual interface{}) []Diff {
+	switch exp := expected.(type) {
+	case map[string]interface{}:
+		act, ok := actual.(map[string]interface{})
+		if !ok {
+			return []Diff{{Path: path, Expected: expected, Actual: actual}}
+		}
+
+		keys := make([]string, 0, len(exp))
+		for key := range exp {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		var diffs []Diff
+		for _, key := range keys {
+			value, ok := act[key]
+			if !ok {
+				diffs = append(diffs, Diff{Path: path + "." + key, Expected: exp[key], Missing: true})
+				continue
+			}
+
+			diffs = append(diffs, diff(path+"."+key, exp[key], value)...)
+		}
+
+		return diffs
+
+	case []interface{}:
+		act, ok := actual.([]interface{})
+		if !ok || len(act) != len(exp) {
+			return []Diff{{Path: path, Expected: expected, Actual: actual}}
+		}
+
+		var diffs []Diff
+		for i := range exp {
+			diffs = append(diffs, diff(fmt.Sprintf("%s[%d]", path, i), exp[i], act[i])...)
+		}
+
+		return diffs
+
+	default:
+		// util.Compare сравнивает числа json.Number по значению: 5 и 5.0 равны
+		if util.Compare(expected, actual) != 0 {
+			return []Diff{{Path: path, Expected: expected, Actual: actual}}
+		}
+
+		return nil
+	}
+}
+
+// normalize приводит ожидаемое значение к JSON-виду, в котором OPA возвращает результат
+func normalize(v interface{}) interface{} {
+	if err := util.RoundTrip(&v); err != nil {
+		return v
+	}
+
+	return v
+}
+
+func formatValue(v interface{}) string {
+	if v == nil {
+		return "null"
+	}
+
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+
+	return string(raw)
+}
@@ -0,0 +1,196 @@
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/open-policy-agent/opa/util"
+)
+
+// placeholder параметр сообщения вида {field}
+var placeholder = regexp.MustCompile(`\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// Catalog сообщения о нарушениях на разных языках, ключом служит код нарушения.
+// Сообщение - шаблон с параметрами в фигурных скобках, например
+// "Для поля {field} ожидалось значение {expected}, получено {actual}".
+// В шаблоне доступны поля нарушения field, expected, actual, code и параметры из Violation.Params.
+type Catalog struct {
+	// messages язык -> код нарушения -> шаблон сообщения
+	messages map[string]map[string]string
+}
+
+// LoadCatalog загружает каталог из директории dir, в которой для каждого языка лежит файл
+// <язык>.json, <язык>.yaml или <язык>.yml с объектом "код нарушения": "шаблон сообщения", например ru.json и en.json.
+func LoadCatalog(dir string) (*Catalog, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка загрузки каталога сообщений: %w", err)
+	}
+
+	c := &Catalog{
+		messages: make(map[string]map[string]string),
+	}
+
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		if entry.IsDir() || (ext != ".json" && ext != ".yaml" && ext != ".yml") {
+			continue
+		}
+
+		raw, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("ошибка загрузки каталога сообщений: %w", err)
+		}
+
+		// util.Unmarshal разбирает и JSON, и YAML
+		var messages map[string]string
+		if err = util.Unmarshal(raw, &messages); err != nil {
+			return nil, fmt.Errorf("ошибка загрузки сообщений из %s: %w", entry.Name(), err)
+		}
+
+		c.messages[normalizeLocale(strings.TrimSuffix(entry.Name(), ext))] = messages
+	}
+
+	if len(c.messages) == 0 {
+		return nil, fmt.Errorf("в директории %s нет файлов с сообщениями", dir)
+	}
+
+	return c, nil
+}
+
+// Locales возвращает языки каталога
+func (c *Catalog) Locales() []string {
+	locales := make([]string, 0, len(c.messages))
+	for locale := range c.messages {
+		locales = append(locales, locale)
+	}
+	sort.Strings(locales)
+
+	return locales
+}
+
+// Message возвращает сообщение о нарушении на языке locale, например ru, en-US или значение заголовка Accept-Language.
+// Если для языка нет сообщения с кодом нарушения, возвращается подсказка из политики.
+func (c *Catalog) Message(locale string, v Violation) string {
+	tmpl, ok := c.lookup(locale, v.Code)
+	if !ok {
+		if v.Hint != "" {
+			return v.Hint
+		}
+
+		return v.String()
+	}
+
+	params := map[string]interface{}{
+		"code":     v.Code,
+		"field":    v.Field,
+		"expected": v.Expected,
+		"actual":   v.Actual,
+	}
+	for name, value := range v.Params {
+		params[name] = value
+	}
+
+	return placeholder.ReplaceAllStringFunc(tmpl, func(match string) string {
+		value, ok := params[match[1:len(match)-1]]
+		if !ok {
+			return match
+		}
+
+		return formatParam(value)
+	})
+}
+
+// Localize возвращает копию нарушений, в которых подсказка заменена сообщением на языке locale
+func (c *Catalog) Localize(locale string, violations []Violation) []Violation {
+	localized := make([]Violation, len(violations))
+	for i, v := range violations {
+		v.Hint = c.Message(locale, v)
+		localized[i] = v
+	}
+
+	return localized
+}
+
+// lookup ищет шаблон сообщения сначала для полного языка (en-us), затем для основного (en).
+// Из списка Accept-Language языки перебираются по убыванию веса q, при равном весе - по порядку.
+func (c *Catalog) lookup(locale, code string) (string, bool) {
+	for _, tag := range acceptLanguages(locale) {
+		candidates := []string{tag}
+		if base, _, ok := strings.Cut(tag, "-"); ok {
+			candidates = append(candidates, base)
+		}
+
+		for _, candidate := range candidates {
+			if tmpl, ok := c.messages[candidate][code]; ok {
+				return tmpl, true
+			}
+		}
+	}
+
+	return "", false
+}
+
+// acceptLanguages разбирает язык или значение заголовка Accept-Language, например "en-US,ru;q=0.9,de;q=0",
+// и возвращает языки в порядке убывания веса. Языки с весом 0 и некорректным весом пропускаются.
+func acceptLanguages(locale string) []string {
+	type weighted struct {
+		tag string
+		q   float64
+	}
+
+	var tags []weighted
+	for _, part := range strings.Split(locale, ",") {
+		tag, params, _ := strings.Cut(part, ";")
+		tag = normalizeLocale(tag)
+		if tag == "" {
+			continue
+		}
+
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			var err error
+			if q, err = strconv.ParseFloat(value, 64); err != nil || q <= 0 || q > 1 {
+				continue
+			}
+		}
+
+		tags = append(tags, weighted{tag: tag, q: q})
+	}
+
+	sort.SliceStable(tags, func(i, j int) bool {
+		return tags[i].q > tags[j].q
+	})
+
+	locales := make([]string, len(tags))
+	for i, t := range tags {
+		locales[i] = t.tag
+	}
+
+	return locales
+}
+
+func normalizeLocale(locale string) string {
+	return strings.ReplaceAll(strings.ToLower(strings.TrimSpace(locale)), "_", "-")
+}
+
+// formatParam выводит значение параметра: строки как есть, остальные значения (числа, null, массивы, объекты) в JSON,
+// чтобы строка "42" и число 42 или массив ["a"] и строка "a" в сообщении различались
+func formatParam(value interface{}) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+
+	return string(raw)
+}
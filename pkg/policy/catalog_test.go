@@ -0,0 +1,169 @@
+package policy
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func newTestCatalog(t *testing.T) *Catalog {
+	t.Helper()
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"ru.json": `{"permission_missing": "Для действия {action} нужно право {expected}"}`,
+		"en.yaml": `permission_missing: "Permission {expected} is required for action {action}"
+resource_field_mismatch: "Expected {expected} for {field}, but got {actual} ({unknown})"
+`,
+		"en_GB.json": `{"permission_missing": "Permission {expected} is required to {action}"}`,
+		// Файлы других форматов пропускаются
+		"README.md": "# сообщения",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	catalog, err := LoadCatalog(dir)
+	if err != nil {
+		t.Fatalf("LoadCatalog: %v", err)
+	}
+
+	if got := catalog.Locales(); !reflect.DeepEqual(got, []string{"en", "en-gb", "ru"}) {
+		t.Fatalf("языки каталога %v", got)
+	}
+
+	return catalog
+}
+
+func TestCatalogLocale(t *testing.T) {
+	catalog := newTestCatalog(t)
+
+	violation := Violation{
+		Code:     "permission_missing",
+		Field:    "user_permissions",
+		Expected: "write",
+		Actual:   []interface{}{"read"},
+		Params:   map[string]interface{}{"action": "update"},
+		Hint:     "hint from policy",
+	}
+
+	const (
+		ru   = "Для действия update нужно право write"
+		en   = "Permission write is required for action update"
+		enGB = "Permission write is required to update"
+		hint = "hint from policy"
+	)
+
+	tests := []struct {
+		locale string
+		want   string
+	}{
+		{locale: "ru", want: ru},
+		{locale: "en", want: en},
+		{locale: "EN_gb", want: enGB},
+		// Для en-US нет сообщений, используется основной язык
+		{locale: "en-US", want: en},
+		{locale: "de", want: hint},
+		{locale: "", want: hint},
+		{locale: "*", want: hint},
+		// Accept-Language: языки перебираются по убыванию веса
+		{locale: "de-DE, en;q=0.5", want: en},
+		{locale: "en;q=0.5, ru;q=0.9", want: ru},
+		{locale: "en-GB;q=0.8, en;q=0.8, ru;q=0.7", want: enGB},
+		{locale: "ru;q=0, en", want: en},
+		{locale: "ru;q=abc, en;q=0.1", want: en},
+		{locale: " ru ; q=1 ", want: ru},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.locale, func(t *testing.T) {
+			if got := catalog.Message(tt.locale, violation); got != tt.want {
+				t.Errorf("Message(%q) = %q, ожидалось %q", tt.locale, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCatalogPlaceholders(t *testing.T) {
+	catalog := newTestCatalog(t)
+
+	tests := []struct {
+		name      string
+		violation Violation
+		want      string
+	}{
+		{
+			name:      "строки подставляются как есть",
+			violation: Violation{Code: "resource_field_mismatch", Field: "source_slug", Expected: "some_slug", Actual: "other"},
+			want:      "Expected some_slug for source_slug, but got other ({unknown})",
+		},
+		{
+			name:      "остальные значения в JSON",
+			violation: Violation{Code: "resource_field_mismatch", Field: "source_uuid", Expected: "uuid", Actual: 42},
+			want:      "Expected uuid for source_uuid, but got 42 ({unknown})",
+		},
+		{
+			name:      "отсутствующее значение",
+			violation: Violation{Code: "resource_field_mismatch", Field: "source_slug", Expected: []interface{}{"a", "b"}},
+			want:      `Expected ["a","b"] for source_slug, but got null ({unknown})`,
+		},
+		{
+			name:      "параметр нарушения",
+			violation: Violation{Code: "resource_field_mismatch", Field: "source_slug", Params: map[string]interface{}{"unknown": "param"}},
+			want:      "Expected null for source_slug, but got null (param)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := catalog.Message("en", tt.violation); got != tt.want {
+				t.Errorf("Message = %q, ожидалось %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCatalogFallback(t *testing.T) {
+	catalog := newTestCatalog(t)
+
+	tests := []struct {
+		name      string
+		violation Violation
+		want      string
+	}{
+		{
+			name:      "подсказка из политики",
+			violation: Violation{Code: "role_cycle", Field: "role", Actual: "admin", Hint: "Role admin inherits itself"},
+			want:      "Role admin inherits itself",
+		},
+		{
+			name:      "без подсказки",
+			violation: Violation{Code: "role_cycle", Field: "role", Expected: "no cycle", Actual: "admin"},
+			want:      "role (role_cycle): ожидалось no cycle, получено admin",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := catalog.Message("ru", tt.violation); got != tt.want {
+				t.Errorf("Message = %q, ожидалось %q", got, tt.want)
+			}
+		})
+	}
+
+	// Localize не меняет исходные нарушения
+	violations := []Violation{{Code: "permission_missing", Expected: "write", Params: map[string]interface{}{"action": "update"}, Hint: "hint"}}
+	localized := catalog.Localize("ru", violations)
+	if localized[0].Hint != "Для действия update нужно право write" || violations[0].Hint != "hint" {
+		t.Errorf("Localize: %q, исходная подсказка %q", localized[0].Hint, violations[0].Hint)
+	}
+}
+
+func TestLoadCatalogEmpty(t *testing.T) {
+	if _, err := LoadCatalog(t.TempDir()); err == nil {
+		t.Error("каталог без файлов с сообщениями загружен без ошибки")
+	}
+}
@@ -0,0 +1,163 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"maps"
+	"path/filepath"
+	"strings"
+
+	"github.com/open-policy-agent/opa/loader"
+	"github.com/open-policy-agent/opa/storage"
+	"github.com/open-policy-agent/opa/storage/inmem"
+)
+
+// DataProvider источник данных для политик на стороне Go, например ресурсы из базы данных.
+// Данные запрашиваются при каждой компиляции политик, то есть при первом запросе и в Engine.Reload.
+type DataProvider interface {
+	Data(ctx context.Context) (interface{}, error)
+}
+
+// DataProviderFunc позволяет использовать функцию как DataProvider
+type DataProviderFunc func(ctx context.Context) (interface{}, error)
+
+// Data вызывает f(ctx)
+func (f DataProviderFunc) Data(ctx context.Context) (interface{}, error) {
+	return f(ctx)
+}
+
+type mountedProvider struct {
+	path     []string
+	provider DataProvider
+}
+
+// WithDataFiles добавляет пути к JSON/YAML-файлам с данными или директориям с ними.
+// Файлы загружаются так же, как это делает opa: содержимое файла монтируется в data
+// по пути его директории относительно переданного пути. Например, файл data.json
+// с содержимым {"resources": {...}} будет доступен в политике как data.resources.
+func WithDataFiles(paths ...string) Option {
+	return func(e *Engine) {
+		e.dataPaths = append(e.dataPaths, paths...)
+	}
+}
+
+// WithData добавляет в data документы верхнего уровня из docs, например загруженные заранее через LoadDataFiles.
+// Документы перекрывают данные из файлов с тем же ключом.
+func WithData(docs map[string]interface{}) Option {
+	return func(e *Engine) {
+		e.data = docs
+	}
+}
+
+// WithDataProvider монтирует данные из provider в data по пути path,
+// например WithDataProvider("resources", p) делает их доступными как data.resources.
+// Данные провайдера перекрывают данные из файлов по тому же пути.
+func WithDataProvider(path string, provider DataProvider) Option {
+	return func(e *Engine) {
+		e.providers = append(e.providers, mountedProvider{
+			path:     strings.Split(strings.TrimPrefix(path, "data."), "."),
+			provider: provider,
+		})
+	}
+}
+
+// hasData сообщает, нужно ли создавать хранилище для движка
+func (e *Engine) hasData() bool {
+	return len(e.dataPaths) > 0 || e.data != nil || len(e.providers) > 0
+}
+
+// loadData собирает документ data из файлов с данными, документов WithData и провайдеров
+func (e *Engine) loadData(ctx context.Context) (map[string]interface{}, error) {
+	docs := make(map[string]interface{})
+
+	if len(e.dataPaths) > 0 {
+		var err error
+		if docs, err = LoadDataFiles(e.dataPaths...); err != nil {
+			return nil, err
+		}
+	}
+
+	for key, value := range e.data {
+		docs[key] = value
+	}
+
+	for _, p := range e.providers {
+		value, err := p.provider.Data(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка получения данных для data.%s: %w", strings.Join(p.path, "."), err)
+		}
+
+		if err = mount(docs, p.path, value); err != nil {
+			return nil, err
+		}
+	}
+
+	return docs, nil
+}
+
+// LoadDataFiles загружает документ data из JSON/YAML-файлов или директорий с ними так же, как WithDataFiles
+func LoadDataFiles(paths ...string) (map[string]interface{}, error) {
+	res, err := loader.NewFileLoader().Filtered(paths, dataFilesOnly)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка загрузки данных: %w", err)
+	}
+
+	if res.Documents == nil {
+		return make(map[string]interface{}), nil
+	}
+
+	return res.Documents, nil
+}
+
+// newStore создает хранилище OPA с документом data
+func newStore(ctx context.Context, docs map[string]interface{}) (storage.Store, error) {
+	store := inmem.New()
+	err := storage.WriteOne(ctx, store, storage.AddOp, storage.Path{}, docs)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка записи данных в хранилище: %w", err)
+	}
+
+	return store, nil
+}
+
+// mount записывает value во вложенный объект docs по пути path, создавая промежуточные объекты.
+// Существующие промежуточные объекты копируются: они могут принадлежать документу WithData,
+// который используется несколькими движками одновременно.
+func mount(docs map[string]interface{}, path []string, value interface{}) error {
+	node := docs
+	for i, key := range path[:len(path)-1] {
+		next, ok := node[key].(map[string]interface{})
+		if !ok {
+			if _, exists := node[key]; exists {
+				return fmt.Errorf("невозможно смонтировать данные в data.%s: data.%s не является объектом",
+					strings.Join(path, "."), strings.Join(path[:i+1], "."))
+			}
+
+			next = make(map[string]interface{})
+		} else {
+			next = maps.Clone(next)
+		}
+
+		node[key] = next
+		node = next
+	}
+
+	node[path[len(path)-1]] = value
+
+	return nil
+}
+
+// dataFilesOnly фильтр для загрузчика, который оставляет только файлы с данными
+func dataFilesOnly(_ string, info fs.FileInfo, _ int) bool {
+	if info.IsDir() {
+		return false
+	}
+
+	switch filepath.Ext(info.Name()) {
+	case ".json", ".yaml", ".yml":
+		return false
+	default:
+		return true
+	}
+}
@@ -0,0 +1,75 @@
+package policy
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+type tenantResult struct {
+	Name      string `json:"name"`
+	Resources []int  `json:"resources"`
+}
+
+// TestWithDataProviderMount проверяет, что провайдер, смонтированный внутрь документа WithData,
+// не меняет этот документ, даже если он общий для нескольких движков
+func TestWithDataProviderMount(t *testing.T) {
+	ctx := context.Background()
+
+	shared := map[string]interface{}{
+		"tenant": map[string]interface{}{"name": "acme"},
+	}
+	want := map[string]interface{}{
+		"tenant": map[string]interface{}{"name": "acme"},
+	}
+
+	const module = `package tenant
+
+result := {"name": data.tenant.name, "resources": data.tenant.resources}
+`
+
+	engines := make([]*Engine, 4)
+	for i := range engines {
+		resources := []interface{}{i}
+		engines[i] = New("data.tenant.result",
+			WithModule("tenant.rego", module),
+			WithData(shared),
+			WithDataProvider("tenant.resources", DataProviderFunc(func(context.Context) (interface{}, error) {
+				return resources, nil
+			})),
+		)
+	}
+
+	// Движки компилируются одновременно. Запись в общий документ обнаруживается при запуске с -race.
+	var wg sync.WaitGroup
+	for _, engine := range engines {
+		wg.Add(1)
+		go func(engine *Engine) {
+			defer wg.Done()
+			if err := engine.Prepare(ctx); err != nil {
+				t.Error(err)
+			}
+		}(engine)
+	}
+	wg.Wait()
+
+	for i, engine := range engines {
+		decision, err := engine.Eval(ctx, nil)
+		if err != nil {
+			t.Fatalf("Eval: %v", err)
+		}
+
+		got, err := Decode[tenantResult](decision)
+		if err != nil {
+			t.Fatalf("Decode: %v", err)
+		}
+		if want := (tenantResult{Name: "acme", Resources: []int{i}}); !reflect.DeepEqual(got, want) {
+			t.Errorf("движок %d: результат %+v, ожидалось %+v", i, got, want)
+		}
+	}
+
+	if !reflect.DeepEqual(shared, want) {
+		t.Errorf("документ WithData изменился: %v", shared)
+	}
+}
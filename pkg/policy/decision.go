@@ -0,0 +1,52 @@
+package policy
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrUndefined возвращается, когда запрос к политике не вернул результат
+var ErrUndefined = errors.New("политика не вернула результат")
+
+// Decision результат вычисления запроса к политике
+type Decision struct {
+	// ID идентификатор решения, по которому его можно найти в логах
+	ID string
+	// Query запрос, в результате которого получено решение
+	Query string
+	// Revision ревизия политик и данных, по которым вычислено решение
+	Revision string
+	// Defined false, если правило, к которому выполнялся запрос, не определено для входных данных
+	Defined bool
+	// Value значение первого выражения запроса в том виде, в котором его вернул OPA
+	Value interface{}
+}
+
+// Bool возвращает решение как bool.
+// Неопределенное правило трактуется как false, так же как и при отсутствии default в политике.
+func (d Decision) Bool() (bool, error) {
+	if !d.Defined {
+		return false, nil
+	}
+
+	allowed, ok := d.Value.(bool)
+	if !ok {
+		return false, fmt.Errorf("невозможно преобразовать результат в bool")
+	}
+
+	return allowed, nil
+}
+
+// Object возвращает решение как объект
+func (d Decision) Object() (map[string]interface{}, error) {
+	if !d.Defined {
+		return nil, ErrUndefined
+	}
+
+	res, ok := d.Value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("невозможно преобразовать результат в map[string]interface{}")
+	}
+
+	return res, nil
+}
@@ -0,0 +1,177 @@
+// Package decisionlog содержит встроенные получатели журнала решений движка policy:
+// файл в формате JSONL с ротацией и хранилище в памяти.
+package decisionlog
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sync"
+
+	"github.com/olezhek28/access_policy/pkg/policy"
+)
+
+const (
+	defaultMaxSize    = 100 << 20
+	defaultMaxBackups = 5
+)
+
+// FileSink пишет решения в файл по одной JSON-записи на строку.
+// Когда размер файла превышает лимит, файл переименовывается в <path>.1,
+// предыдущие копии сдвигаются (<path>.1 -> <path>.2 и т.д.), а самые старые удаляются.
+type FileSink struct {
+	path       string
+	maxSize    int64
+	maxBackups int
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+// FileOption настраивает FileSink
+type FileOption func(s *FileSink)
+
+// WithMaxSize задает размер файла в байтах, после которого он ротируется. По-умолчанию 100 МБ.
+func WithMaxSize(size int64) FileOption {
+	return func(s *FileSink) {
+		s.maxSize = size
+	}
+}
+
+// WithMaxBackups задает число хранимых ротированных файлов. По-умолчанию 5.
+func WithMaxBackups(n int) FileOption {
+	return func(s *FileSink) {
+		s.maxBackups = n
+	}
+}
+
+// NewFileSink открывает файл журнала path на дозапись
+func NewFileSink(path string, opts ...FileOption) (*FileSink, error) {
+	s := &FileSink{
+		path:       path,
+		maxSize:    defaultMaxSize,
+		maxBackups: defaultMaxBackups,
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Log дописывает запись в файл
+func (s *FileSink) Log(_ context.Context, record policy.DecisionRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("ошибка сериализации записи журнала: %w", err)
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return errors.New("файл журнала решений закрыт")
+	}
+
+	// Запись, которая больше лимита сама по себе, пишется в пустой файл без повторной ротации.
+	// Если ротация не удалась, запись дописывается в текущий файл, а ошибка ротации возвращается.
+	var rotateErr error
+	if s.size > 0 && s.size+int64(len(line)) > s.maxSize {
+		if rotateErr = s.rotate(); s.file == nil {
+			return rotateErr
+		}
+	}
+
+	n, err := s.file.Write(line)
+	s.size += int64(n)
+	if err != nil {
+		return errors.Join(rotateErr, fmt.Errorf("ошибка записи в журнал решений: %w", err))
+	}
+
+	return rotateErr
+}
+
+// Close закрывает файл журнала
+func (s *FileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return nil
+	}
+
+	err := s.file.Close()
+	s.file = nil
+
+	return err
+}
+
+func (s *FileSink) open() error {
+	file, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("ошибка открытия журнала решений: %w", err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return fmt.Errorf("ошибка открытия журнала решений: %w", err)
+	}
+
+	s.file = file
+	s.size = info.Size()
+
+	return nil
+}
+
+// rotate сдвигает ротированные копии и начинает новый файл.
+// При ошибке текущий файл открывается снова, чтобы одна неудачная ротация не отключала журнал:
+// записи продолжают дописываться в него сверх лимита, а ротация повторяется при следующей записи.
+func (s *FileSink) rotate() error {
+	err := s.file.Close()
+	s.file = nil
+	if err == nil {
+		err = s.shift()
+	}
+
+	if err != nil {
+		err = fmt.Errorf("ошибка ротации журнала решений: %w", err)
+		if openErr := s.open(); openErr != nil {
+			return errors.Join(err, openErr)
+		}
+
+		return err
+	}
+
+	return s.open()
+}
+
+// shift переименовывает текущий файл в <path>.1, сдвигая предыдущие копии, или удаляет его, если копии не хранятся
+func (s *FileSink) shift() error {
+	if s.maxBackups <= 0 {
+		return os.Remove(s.path)
+	}
+
+	for i := s.maxBackups - 1; i > 0; i-- {
+		err := os.Rename(s.backup(i), s.backup(i+1))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+
+	return os.Rename(s.path, s.backup(1))
+}
+
+func (s *FileSink) backup(i int) string {
+	return fmt.Sprintf("%s.%d", s.path, i)
+}
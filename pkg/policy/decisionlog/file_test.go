@@ -0,0 +1,235 @@
+package decisionlog
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/olezhek28/access_policy/pkg/policy"
+)
+
+// recordSize размер одной записи testRecord в файле вместе с переводом строки
+func recordSize(t *testing.T) int64 {
+	t.Helper()
+
+	line, err := json.Marshal(testRecord(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return int64(len(line)) + 1
+}
+
+func testRecord(i int) policy.DecisionRecord {
+	return policy.DecisionRecord{
+		DecisionID: fmt.Sprintf("decision-%d", i),
+		Query:      "data.final_check.result",
+	}
+}
+
+func TestFileSinkRotation(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "decisions.jsonl")
+
+	// В файл помещается ровно две записи, поэтому каждая третья запись начинает новый файл
+	sink, err := NewFileSink(path, WithMaxSize(2*recordSize(t)), WithMaxBackups(2))
+	if err != nil {
+		t.Fatalf("NewFileSink: %v", err)
+	}
+	defer sink.Close()
+
+	for i := 1; i <= 7; i++ {
+		if err = sink.Log(ctx, testRecord(i)); err != nil {
+			t.Fatalf("Log %d: %v", i, err)
+		}
+	}
+
+	// Хранятся только две ротированные копии: записи 1 и 2 удалены вместе с самой старой копией
+	want := map[string][]string{
+		path:        {"decision-7"},
+		path + ".1": {"decision-5", "decision-6"},
+		path + ".2": {"decision-3", "decision-4"},
+	}
+	for file, ids := range want {
+		if got := readIDs(t, file); fmt.Sprint(got) != fmt.Sprint(ids) {
+			t.Errorf("%s: записи %v, ожидались %v", filepath.Base(file), got, ids)
+		}
+	}
+
+	if _, err = os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("копия сверх WithMaxBackups не удалена: %v", err)
+	}
+}
+
+func TestFileSinkRotationWithoutBackups(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "decisions.jsonl")
+
+	sink, err := NewFileSink(path, WithMaxSize(recordSize(t)), WithMaxBackups(0))
+	if err != nil {
+		t.Fatalf("NewFileSink: %v", err)
+	}
+	defer sink.Close()
+
+	for i := 1; i <= 3; i++ {
+		if err = sink.Log(ctx, testRecord(i)); err != nil {
+			t.Fatalf("Log %d: %v", i, err)
+		}
+	}
+
+	if got := readIDs(t, path); fmt.Sprint(got) != "[decision-3]" {
+		t.Errorf("записи %v, ожидалась только последняя", got)
+	}
+	if _, err = os.Stat(path + ".1"); !os.IsNotExist(err) {
+		t.Errorf("без копий файл не должен ротироваться в .1: %v", err)
+	}
+}
+
+// TestFileSinkRotationFailure проверяет, что после неудачной ротации журнал продолжает писаться в текущий файл
+func TestFileSinkRotationFailure(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "decisions.jsonl")
+
+	sink, err := NewFileSink(path, WithMaxSize(recordSize(t)), WithMaxBackups(1))
+	if err != nil {
+		t.Fatalf("NewFileSink: %v", err)
+	}
+	defer sink.Close()
+
+	if err = sink.Log(ctx, testRecord(1)); err != nil {
+		t.Fatalf("Log 1: %v", err)
+	}
+
+	// Непустая директория на месте копии не дает переименовать файл журнала
+	if err = os.MkdirAll(filepath.Join(path+".1", "busy"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = sink.Log(ctx, testRecord(2)); err == nil {
+		t.Fatal("ожидалась ошибка ротации")
+	}
+	if err = sink.Log(ctx, testRecord(3)); err == nil {
+		t.Fatal("ожидалась ошибка повторной ротации")
+	}
+
+	if got := readIDs(t, path); fmt.Sprint(got) != "[decision-1 decision-2 decision-3]" {
+		t.Errorf("записи %v, ожидались все три записи в текущем файле", got)
+	}
+
+	// Когда причина устранена, следующая запись ротирует файл
+	if err = os.RemoveAll(path + ".1"); err != nil {
+		t.Fatal(err)
+	}
+	if err = sink.Log(ctx, testRecord(4)); err != nil {
+		t.Fatalf("Log 4: %v", err)
+	}
+
+	if got := readIDs(t, path); fmt.Sprint(got) != "[decision-4]" {
+		t.Errorf("записи %v после ротации, ожидалась decision-4", got)
+	}
+	if got := readIDs(t, path+".1"); len(got) != 3 {
+		t.Errorf("в копии записи %v, ожидались три записи", got)
+	}
+}
+
+func TestFileSinkMask(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "decisions.jsonl")
+
+	sink, err := NewFileSink(path)
+	if err != nil {
+		t.Fatalf("NewFileSink: %v", err)
+	}
+
+	engine := policy.New("data.authz.result",
+		policy.WithModule("authz.rego", `package authz
+
+result := {"allow": input.role == "admin", "user": input.user}
+`),
+		policy.WithDecisionLog(sink),
+		policy.WithDecisionMask("input.user.token", "result.user"),
+	)
+
+	input := map[string]interface{}{
+		"role": "admin",
+		"user": map[string]interface{}{"name": "alice", "token": "secret"},
+	}
+	decision, err := engine.Eval(ctx, input)
+	if err != nil {
+		t.Fatalf("Eval: %v", err)
+	}
+	if err = sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Маскирование не меняет ни входные данные вызывающего кода, ни возвращаемое решение
+	if input["user"].(map[string]interface{})["token"] != "secret" {
+		t.Error("маскирование изменило входные данные вызывающего кода")
+	}
+	if _, ok := decision.Value.(map[string]interface{})["user"]; !ok {
+		t.Error("маскирование изменило возвращаемое решение")
+	}
+
+	records := readRecords(t, path)
+	if len(records) != 1 {
+		t.Fatalf("записей %d, ожидалась 1", len(records))
+	}
+	record := records[0]
+
+	if record.DecisionID != decision.ID {
+		t.Errorf("decision_id %q, ожидался %q", record.DecisionID, decision.ID)
+	}
+	if fmt.Sprint(record.Erased) != "[input.user.token result.user]" {
+		t.Errorf("erased %v", record.Erased)
+	}
+
+	user := record.Input.(map[string]interface{})["user"].(map[string]interface{})
+	if _, ok := user["token"]; ok || user["name"] != "alice" {
+		t.Errorf("input.user в журнале %v, ожидалось только имя", user)
+	}
+
+	result := record.Result.(map[string]interface{})
+	if _, ok := result["user"]; ok || result["allow"] != true {
+		t.Errorf("result в журнале %v, ожидалось только allow", result)
+	}
+}
+
+func readRecords(t *testing.T, path string) []policy.DecisionRecord {
+	t.Helper()
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	var records []policy.DecisionRecord
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var record policy.DecisionRecord
+		if err = json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		records = append(records, record)
+	}
+	if err = scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	return records
+}
+
+func readIDs(t *testing.T, path string) []string {
+	t.Helper()
+
+	var ids []string
+	for _, record := range readRecords(t, path) {
+		ids = append(ids, record.DecisionID)
+	}
+
+	return ids
+}
@@ -0,0 +1,68 @@
+package decisionlog
+
+import (
+	"context"
+	"sync"
+
+	"github.com/olezhek28/access_policy/pkg/policy"
+)
+
+// MemorySink хранит записи журнала решений в памяти, например для тестов или отладочного API.
+// При заданном лимите хранятся только последние limit записей.
+type MemorySink struct {
+	limit int
+
+	mu      sync.Mutex
+	records []policy.DecisionRecord
+}
+
+// NewMemorySink создает хранилище на limit последних записей. При limit <= 0 записи не вытесняются.
+func NewMemorySink(limit int) *MemorySink {
+	return &MemorySink{limit: limit}
+}
+
+// Log сохраняет запись
+func (s *MemorySink) Log(_ context.Context, record policy.DecisionRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.records = append(s.records, record)
+	if s.limit > 0 && len(s.records) > s.limit {
+		s.records = append(s.records[:0], s.records[len(s.records)-s.limit:]...)
+	}
+
+	return nil
+}
+
+// Records возвращает копию сохраненных записей в порядке поступления
+func (s *MemorySink) Records() []policy.DecisionRecord {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	records := make([]policy.DecisionRecord, len(s.records))
+	copy(records, s.records)
+
+	return records
+}
+
+// Find возвращает запись по идентификатору решения
+func (s *MemorySink) Find(decisionID string) (policy.DecisionRecord, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := len(s.records) - 1; i >= 0; i-- {
+		if s.records[i].DecisionID == decisionID {
+			return s.records[i], true
+		}
+	}
+
+	return policy.DecisionRecord{}, false
+}
+
+// Reset удаляет все сохраненные записи
+func (s *MemorySink) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.records = nil
+}
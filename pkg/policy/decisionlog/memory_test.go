@@ -0,0 +1,44 @@
+package decisionlog
+
+import (
+	"context"
+	"testing"
+
+	"github.com/olezhek28/access_policy/pkg/policy"
+)
+
+// TestMemorySinkRejectedInput проверяет, что входные данные, отклоненные до вычисления политики,
+// тоже оставляют запись в журнале решений
+func TestMemorySinkRejectedInput(t *testing.T) {
+	ctx := context.Background()
+	sink := NewMemorySink(10)
+
+	engine := policy.New("data.authz.allow",
+		policy.WithModule("authz.rego", `package authz
+
+default allow = false
+`),
+		policy.WithDecisionLog(sink),
+	)
+
+	// Канал не сериализуется в JSON-документ входных данных
+	input := struct {
+		Role    string   `json:"role"`
+		Updates chan int `json:"updates"`
+	}{Role: "admin", Updates: make(chan int)}
+
+	if _, err := engine.Eval(ctx, input, policy.EvalDecisionID("rejected")); err == nil {
+		t.Fatal("ожидалась ошибка сериализации входных данных")
+	}
+
+	record, ok := sink.Find("rejected")
+	if !ok {
+		t.Fatal("отклоненные входные данные не попали в журнал решений")
+	}
+	if record.Error == "" || record.Query != "data.authz.allow" {
+		t.Errorf("запись %+v, ожидались запрос и ошибка", record)
+	}
+	if record.Input != nil || record.Result != nil {
+		t.Errorf("в записи отклоненного решения input %v, result %v", record.Input, record.Result)
+	}
+}
@@ -0,0 +1,267 @@
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// tagName тег структуры, в котором задается имя ключа в документе политики.
+// Если тег не задан, используется тег json, а при его отсутствии - имя поля.
+//
+// Поддерживаемые опции тега:
+//   - "-" поле пропускается;
+//   - optional - ключ может отсутствовать в документе, поле остается нулевым.
+const tagName = "rego"
+
+// DecodeError ошибка декодирования с путем до значения внутри документа,
+// например result.missing_permissions[2]
+type DecodeError struct {
+	Path string
+	Msg  string
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Path, e.Msg)
+}
+
+// Decode отображает результат запроса к политике на значение типа T.
+// Пути в ошибках начинаются с имени правила, к которому выполнялся запрос.
+func Decode[T any](d Decision) (T, error) {
+	var out T
+	if !d.Defined {
+		return out, ErrUndefined
+	}
+
+	err := DecodeValue(d.Value, &out, ruleName(d.Query))
+	return out, err
+}
+
+// DecodeValue отображает значение, полученное от OPA, на target, который должен быть указателем.
+// root используется как начало пути в сообщениях об ошибках.
+func DecodeValue(value interface{}, target interface{}, root string) error {
+	rv := reflect.ValueOf(target)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return fmt.Errorf("target должен быть ненулевым указателем, получен %T", target)
+	}
+
+	return decodeValue(value, rv.Elem(), root)
+}
+
+func decodeValue(value interface{}, rv reflect.Value, path string) error {
+	if rv.Kind() == reflect.Interface && rv.NumMethod() == 0 {
+		if value != nil {
+			rv.Set(reflect.ValueOf(value))
+		}
+		return nil
+	}
+
+	if rv.Kind() == reflect.Pointer {
+		if value == nil {
+			rv.Set(reflect.Zero(rv.Type()))
+			return nil
+		}
+
+		if rv.IsNil() {
+			rv.Set(reflect.New(rv.Type().Elem()))
+		}
+
+		return decodeValue(value, rv.Elem(), path)
+	}
+
+	switch rv.Kind() {
+	case reflect.Bool:
+		v, ok := value.(bool)
+		if !ok {
+			return typeError(path, "boolean", value)
+		}
+		rv.SetBool(v)
+
+	case reflect.String:
+		v, ok := value.(string)
+		if !ok {
+			return typeError(path, "string", value)
+		}
+		rv.SetString(v)
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, ok := value.(json.Number)
+		if !ok {
+			return typeError(path, "number", value)
+		}
+		v, err := strconv.ParseInt(n.String(), 10, 64)
+		if err != nil || rv.OverflowInt(v) {
+			return &DecodeError{Path: path, Msg: fmt.Sprintf("число %s не помещается в %s", n, rv.Type())}
+		}
+		rv.SetInt(v)
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, ok := value.(json.Number)
+		if !ok {
+			return typeError(path, "number", value)
+		}
+		v, err := strconv.ParseUint(n.String(), 10, 64)
+		if err != nil || rv.OverflowUint(v) {
+			return &DecodeError{Path: path, Msg: fmt.Sprintf("число %s не помещается в %s", n, rv.Type())}
+		}
+		rv.SetUint(v)
+
+	case reflect.Float32, reflect.Float64:
+		n, ok := value.(json.Number)
+		if !ok {
+			return typeError(path, "number", value)
+		}
+		v, err := n.Float64()
+		if err != nil || rv.OverflowFloat(v) {
+			return &DecodeError{Path: path, Msg: fmt.Sprintf("число %s не помещается в %s", n, rv.Type())}
+		}
+		rv.SetFloat(v)
+
+	case reflect.Slice:
+		// Set'ы rego приходят из OPA в виде массивов, поэтому декодируются так же
+		items, ok := value.([]interface{})
+		if !ok {
+			return typeError(path, "array", value)
+		}
+		slice := reflect.MakeSlice(rv.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := decodeValue(item, slice.Index(i), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+		rv.Set(slice)
+
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return &DecodeError{Path: path, Msg: fmt.Sprintf("неподдерживаемый тип ключа %s", rv.Type().Key())}
+		}
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return typeError(path, "object", value)
+		}
+		// Ключи перебираются в отсортированном порядке, чтобы при нескольких некорректных значениях
+		// в ошибке всегда был путь к одному и тому же ключу
+		keys := make([]string, 0, len(obj))
+		for key := range obj {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		m := reflect.MakeMapWithSize(rv.Type(), len(obj))
+		for _, key := range keys {
+			item := obj[key]
+			elem := reflect.New(rv.Type().Elem()).Elem()
+			if err := decodeValue(item, elem, path+"."+key); err != nil {
+				return err
+			}
+			m.SetMapIndex(reflect.ValueOf(key).Convert(rv.Type().Key()), elem)
+		}
+		rv.Set(m)
+
+	case reflect.Struct:
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return typeError(path, "object", value)
+		}
+		return decodeStruct(obj, rv, path)
+
+	default:
+		return &DecodeError{Path: path, Msg: fmt.Sprintf("неподдерживаемый тип %s", rv.Type())}
+	}
+
+	return nil
+}
+
+func decodeStruct(obj map[string]interface{}, rv reflect.Value, path string) error {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		key, optional, skip := fieldKey(field)
+		if skip {
+			continue
+		}
+
+		fieldPath := path + "." + key
+		value, ok := obj[key]
+		if !ok {
+			if optional {
+				continue
+			}
+			return &DecodeError{Path: fieldPath, Msg: "поле отсутствует в результате"}
+		}
+
+		if err := decodeValue(value, rv.Field(i), fieldPath); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func fieldKey(field reflect.StructField) (key string, optional bool, skip bool) {
+	tag, ok := field.Tag.Lookup(tagName)
+	if !ok {
+		tag, ok = field.Tag.Lookup("json")
+	}
+	if !ok {
+		return field.Name, false, false
+	}
+	if tag == "-" {
+		return "", false, true
+	}
+
+	name, opts, _ := strings.Cut(tag, ",")
+	if name == "" {
+		name = field.Name
+	}
+
+	for _, opt := range strings.Split(opts, ",") {
+		if opt == "optional" || opt == "omitempty" {
+			optional = true
+		}
+	}
+
+	return name, optional, false
+}
+
+func typeError(path, expected string, value interface{}) error {
+	return &DecodeError{
+		Path: path,
+		Msg:  fmt.Sprintf("ожидался %s, получен %s", expected, jsonType(value)),
+	}
+}
+
+func jsonType(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case json.Number, float64, int, int64:
+		return "number"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+// ruleName возвращает последний сегмент запроса: для data.final_check.result это result
+func ruleName(query string) string {
+	if query == "" {
+		return "result"
+	}
+
+	return query[strings.LastIndex(query, ".")+1:]
+}
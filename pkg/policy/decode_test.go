@@ -0,0 +1,164 @@
+package policy
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type decodeViolation struct {
+	Field  string            `rego:"field"`
+	Code   string            `rego:"code"`
+	Params map[string]string `rego:"params,optional"`
+}
+
+type decodeResult struct {
+	AccessAllowed      bool                   `rego:"access_allowed"`
+	MissingPermissions []string               `rego:"missing_permissions"`
+	Violations         []decodeViolation      `rego:"violations"`
+	Limits             map[string]int         `rego:"limits,optional"`
+	Groups             map[string][]string    `rego:"groups,optional"`
+	Resource           *struct{ Slug string } `rego:"resource,optional"`
+}
+
+func TestDecode(t *testing.T) {
+	value := map[string]interface{}{
+		"access_allowed":      false,
+		"missing_permissions": []interface{}{"write"},
+		"violations": []interface{}{
+			map[string]interface{}{
+				"field":  "user_permissions",
+				"code":   "permission_missing",
+				"params": map[string]interface{}{"action": "update"},
+			},
+		},
+		"limits":   map[string]interface{}{"read": json.Number("10")},
+		"resource": map[string]interface{}{"Slug": "some_slug"},
+	}
+
+	got, err := Decode[decodeResult](Decision{Query: "data.final_check.result", Defined: true, Value: value})
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+
+	want := decodeResult{
+		MissingPermissions: []string{"write"},
+		Violations: []decodeViolation{{
+			Field:  "user_permissions",
+			Code:   "permission_missing",
+			Params: map[string]string{"action": "update"},
+		}},
+		Limits:   map[string]int{"read": 10},
+		Resource: &struct{ Slug string }{Slug: "some_slug"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("получено %+v, ожидалось %+v", got, want)
+	}
+}
+
+func TestDecodeUndefined(t *testing.T) {
+	if _, err := Decode[decodeResult](Decision{Query: "data.final_check.result"}); !errors.Is(err, ErrUndefined) {
+		t.Errorf("ошибка %v, ожидалась ErrUndefined", err)
+	}
+}
+
+func TestDecodeErrorPath(t *testing.T) {
+	valid := func() map[string]interface{} {
+		return map[string]interface{}{
+			"access_allowed":      true,
+			"missing_permissions": []interface{}{},
+			"violations":          []interface{}{},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		set      map[string]interface{}
+		drop     string
+		wantPath string
+		wantMsg  string
+	}{
+		{
+			name:     "скалярное поле",
+			set:      map[string]interface{}{"access_allowed": "yes"},
+			wantPath: "result.access_allowed",
+			wantMsg:  "ожидался boolean, получен string",
+		},
+		{
+			name:     "отсутствующее поле",
+			drop:     "violations",
+			wantPath: "result.violations",
+			wantMsg:  "поле отсутствует в результате",
+		},
+		{
+			name:     "элемент слайса",
+			set:      map[string]interface{}{"missing_permissions": []interface{}{"read", "write", json.Number("3")}},
+			wantPath: "result.missing_permissions[2]",
+			wantMsg:  "ожидался string, получен number",
+		},
+		{
+			name:     "вложенная структура в слайсе",
+			set:      map[string]interface{}{"violations": []interface{}{map[string]interface{}{"field": "source_slug", "code": false}}},
+			wantPath: "result.violations[0].code",
+			wantMsg:  "ожидался string, получен boolean",
+		},
+		{
+			name: "map внутри вложенной структуры",
+			set: map[string]interface{}{"violations": []interface{}{map[string]interface{}{
+				"field":  "source_slug",
+				"code":   "resource_field_mismatch",
+				"params": map[string]interface{}{"expected": nil},
+			}}},
+			wantPath: "result.violations[0].params.expected",
+			wantMsg:  "ожидался string, получен null",
+		},
+		{
+			name:     "несколько некорректных ключей map",
+			set:      map[string]interface{}{"limits": map[string]interface{}{"write": "x", "delete": true, "read": json.Number("1")}},
+			wantPath: "result.limits.delete",
+			wantMsg:  "ожидался number, получен boolean",
+		},
+		{
+			name:     "слайс внутри map",
+			set:      map[string]interface{}{"groups": map[string]interface{}{"admins": []interface{}{"alice", json.Number("7")}}},
+			wantPath: "result.groups.admins[1]",
+			wantMsg:  "ожидался string, получен number",
+		},
+		{
+			name:     "число не помещается в тип",
+			set:      map[string]interface{}{"limits": map[string]interface{}{"read": json.Number("1.5")}},
+			wantPath: "result.limits.read",
+			wantMsg:  "число 1.5 не помещается в int",
+		},
+		{
+			name:     "объект вместо массива",
+			set:      map[string]interface{}{"missing_permissions": map[string]interface{}{}},
+			wantPath: "result.missing_permissions",
+			wantMsg:  "ожидался array, получен object",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value := valid()
+			for key, v := range tt.set {
+				value[key] = v
+			}
+			delete(value, tt.drop)
+
+			// Порядок обхода map случаен, поэтому путь в ошибке проверяется на нескольких запусках
+			for i := 0; i < 20; i++ {
+				_, err := Decode[decodeResult](Decision{Query: "data.final_check.result", Defined: true, Value: value})
+
+				var decodeErr *DecodeError
+				if !errors.As(err, &decodeErr) {
+					t.Fatalf("ошибка %v, ожидалась *DecodeError", err)
+				}
+				if decodeErr.Path != tt.wantPath || decodeErr.Msg != tt.wantMsg {
+					t.Fatalf("ошибка %q, ожидалась %q", decodeErr, tt.wantPath+": "+tt.wantMsg)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,431 @@
+package policy
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io/fs"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/open-policy-agent/opa/loader"
+	"github.com/open-policy-agent/opa/rego"
+	"github.com/open-policy-agent/opa/topdown"
+	"github.com/open-policy-agent/opa/util"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// Engine выполняет запрос к набору rego-политик.
+// Политики передаются строками (WithModule, WithModules) или путями к файлам (WithFiles),
+// а данные, доступные в политиках через data, - файлами (WithDataFiles), документами (WithData)
+// или провайдерами (WithDataProvider).
+// Политики компилируются один раз при первом вызове Eval (или явно через Prepare),
+// после чего подготовленный запрос переиспользуется. Engine безопасен для конкурентного использования.
+type Engine struct {
+	query     string
+	modules   []Module
+	paths     []string
+	dataPaths []string
+	data      map[string]interface{}
+	providers []mountedProvider
+	// schemaPath путь к JSON Schema входных данных (WithInputSchema)
+	schemaPath string
+	// schema загруженная схема входных данных (WithLoadedInputSchema)
+	schema *InputSchema
+
+	printLogger *slog.Logger
+
+	sinks []DecisionSink
+	masks [][]string
+
+	// mu защищает компиляцию, чтобы конкурентные первые запросы не компилировали политику повторно
+	mu       sync.Mutex
+	prepared atomic.Pointer[compiledQuery]
+}
+
+// compiledQuery подготовленный запрос вместе с ревизией политик и данных, из которых он собран
+type compiledQuery struct {
+	query    rego.PreparedEvalQuery
+	revision string
+	// schema схема входных данных. nil, если схема не задана.
+	schema *gojsonschema.Schema
+	// opts опции, из которых собран запрос. По ним Explain вычисляет значения ссылок в невыполнившихся выражениях.
+	opts []func(*rego.Rego)
+}
+
+// Option настраивает Engine
+type Option func(e *Engine)
+
+// EvalOption настраивает отдельный вызов Engine.Eval
+type EvalOption func(o *evalOptions)
+
+type evalOptions struct {
+	decisionID  string
+	printLogger *slog.Logger
+	// tracer трассировка вычисления для Explain
+	tracer topdown.QueryTracer
+}
+
+// EvalDecisionID задает идентификатор решения. По-умолчанию генерируется новый UUID.
+func EvalDecisionID(id string) EvalOption {
+	return func(o *evalOptions) {
+		o.decisionID = id
+	}
+}
+
+// EvalPrint включает вывод print() из политик в logger для одного запроса
+func EvalPrint(logger *slog.Logger) EvalOption {
+	return func(o *evalOptions) {
+		o.printLogger = logger
+	}
+}
+
+// WithModule добавляет политику, заданную строкой
+func WithModule(name, source string) Option {
+	return func(e *Engine) {
+		e.modules = append(e.modules, Module{Name: name, Source: source})
+	}
+}
+
+// WithModules добавляет политики, заданные строками, например сгенерированные из шаблонов
+func WithModules(modules ...Module) Option {
+	return func(e *Engine) {
+		e.modules = append(e.modules, modules...)
+	}
+}
+
+// WithFiles добавляет пути к rego-файлам или директориям с ними.
+// Из директорий загружаются только политики: файлы *_test.rego и прочие файлы пропускаются.
+func WithFiles(paths ...string) Option {
+	return func(e *Engine) {
+		e.paths = append(e.paths, paths...)
+	}
+}
+
+// New создает движок для запроса query.
+// Запрос задается полным путем к правилу, например data.final_check.result:
+// data - пространство политик по-умолчанию,
+// final_check - пакет, в котором находится политика,
+// result - именованное правило, в результате которого лежит ответ.
+func New(query string, opts ...Option) *Engine {
+	e := &Engine{
+		query: query,
+	}
+
+	for _, opt := range opts {
+		opt(e)
+	}
+
+	return e
+}
+
+// Query возвращает запрос, который выполняет движок
+func (e *Engine) Query() string {
+	return e.query
+}
+
+// Revision возвращает ревизию скомпилированных политик и данных - хеш их содержимого.
+// До первой компиляции возвращается пустая строка.
+func (e *Engine) Revision() string {
+	if compiled := e.prepared.Load(); compiled != nil {
+		return compiled.revision
+	}
+
+	return ""
+}
+
+// Paths возвращает пути к файлам и директориям, из которых загружаются политики, данные и схема входных данных
+func (e *Engine) Paths() []string {
+	paths := make([]string, 0, len(e.paths)+len(e.dataPaths)+1)
+	paths = append(paths, e.paths...)
+	paths = append(paths, e.dataPaths...)
+	if e.schemaPath != "" {
+		paths = append(paths, e.schemaPath)
+	}
+
+	return paths
+}
+
+// Eval вычисляет запрос для входных данных input.
+// input становится доступен внутри политики через переменную input. Кроме map можно передать структуру
+// с json-тегами (например сгенерированную inputgen по схеме входных данных): она сериализуется в JSON-документ.
+// Если задана схема входных данных (WithInputSchema), input, не прошедший проверку, не вычисляется,
+// а возвращается ошибка *InputError.
+// Если подключен журнал решений (WithDecisionLog), каждое вычисление, в том числе неуспешное, записывается в него.
+func (e *Engine) Eval(ctx context.Context, input interface{}, opts ...EvalOption) (Decision, error) {
+	o := evalOptions{
+		printLogger: e.printLogger,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.decisionID == "" {
+		o.decisionID = uuid.NewString()
+	}
+
+	start := time.Now()
+	input, err := inputDocument(input)
+	if err != nil {
+		// Входные данные, которые не сериализуются, тоже записываются в журнал как решение с ошибкой,
+		// но без самих входных данных: их нельзя замаскировать
+		if len(e.sinks) > 0 {
+			e.logDecision(ctx, start, nil, Decision{ID: o.decisionID, Query: e.query, Revision: e.Revision()}, err)
+		}
+		return Decision{}, err
+	}
+
+	decision, err := e.eval(ctx, input, o)
+	if len(e.sinks) > 0 {
+		e.logDecision(ctx, start, input, decision, err)
+	}
+	if err != nil {
+		return Decision{}, err
+	}
+
+	return decision, nil
+}
+
+// eval вычисляет запрос. При ошибке возвращает решение с уже известными полями для журнала решений.
+func (e *Engine) eval(ctx context.Context, input interface{}, o evalOptions) (Decision, error) {
+	decision := Decision{
+		ID:    o.decisionID,
+		Query: e.query,
+	}
+
+	compiled, err := e.prepare(ctx)
+	if err != nil {
+		return decision, err
+	}
+	decision.Revision = compiled.revision
+
+	if compiled.schema != nil {
+		if err = validateInput(compiled.schema, input); err != nil {
+			return decision, err
+		}
+	}
+
+	var evalOpts []rego.EvalOption
+	if input != nil {
+		evalOpts = append(evalOpts, rego.EvalInput(input))
+	}
+	if o.printLogger != nil {
+		evalOpts = append(evalOpts, rego.EvalPrintHook(printHook{
+			logger:     o.printLogger,
+			query:      e.query,
+			decisionID: o.decisionID,
+		}))
+	}
+	if o.tracer != nil {
+		evalOpts = append(evalOpts, rego.EvalQueryTracer(o.tracer))
+	}
+
+	// Выполнение запроса
+	rs, err := compiled.query.Eval(ctx, evalOpts...)
+	if err != nil {
+		return decision, fmt.Errorf("ошибка при оценке политики: %w", err)
+	}
+
+	// При простом запросе, как `data.authorization.allow`,
+	// ответ будет содержать лишь один элемент с единственным выражением.
+	if len(rs) == 0 || len(rs[0].Expressions) == 0 {
+		return decision, nil
+	}
+
+	decision.Defined = true
+	decision.Value = rs[0].Expressions[0].Value
+
+	return decision, nil
+}
+
+// Prepare компилирует политики заранее, чтобы ошибки компиляции обнаруживались до первого запроса.
+// Повторный вызов после успешной компиляции ничего не делает.
+func (e *Engine) Prepare(ctx context.Context) error {
+	_, err := e.prepare(ctx)
+	return err
+}
+
+// Reload заново загружает и компилирует политики.
+// Подготовленный запрос подменяется атомарно и только при успешной компиляции,
+// поэтому при ошибке в политике движок продолжает работать с последней корректной версией.
+func (e *Engine) Reload(ctx context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	compiled, err := e.compile(ctx)
+	if err != nil {
+		return err
+	}
+
+	e.prepared.Store(compiled)
+
+	return nil
+}
+
+func (e *Engine) prepare(ctx context.Context) (*compiledQuery, error) {
+	if compiled := e.prepared.Load(); compiled != nil {
+		return compiled, nil
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	// Пока ждали блокировку, политику мог скомпилировать другой запрос
+	if compiled := e.prepared.Load(); compiled != nil {
+		return compiled, nil
+	}
+
+	compiled, err := e.compile(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	e.prepared.Store(compiled)
+
+	return compiled, nil
+}
+
+func (e *Engine) compile(ctx context.Context) (*compiledQuery, error) {
+	revision := sha256.New()
+
+	opts, err := e.regoOptions(ctx, revision)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при компиляции политики: %w", err)
+	}
+
+	inputSchema, err := e.inputSchema()
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при компиляции политики: %w", err)
+	}
+
+	var schema *gojsonschema.Schema
+	if inputSchema != nil {
+		writeRevision(revision, inputSchema.path, inputSchema.raw)
+		opts = append(opts, rego.Schemas(inputSchema.SchemaSet()))
+		schema = inputSchema.schema
+	}
+
+	// Метод PrepareForEval используется для предварительной подготовки
+	// запроса, чтобы его можно было повторно использовать с разными входными
+	// данными без необходимости заново загружать и компилировать политику каждый раз.
+	// Подготовленный запрос безопасен для конкурентного вызова Eval.
+	query, err := rego.New(opts...).PrepareForEval(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при компиляции политики: %w", err)
+	}
+
+	return &compiledQuery{
+		query:    query,
+		revision: hex.EncodeToString(revision.Sum(nil)),
+		schema:   schema,
+		opts:     opts,
+	}, nil
+}
+
+// regoOptions собирает опции компиляции и записывает в revision содержимое политик и данных
+func (e *Engine) regoOptions(ctx context.Context, revision hash.Hash) ([]func(*rego.Rego), error) {
+	opts := []func(*rego.Rego){
+		rego.Query(e.query),
+		// Вызовы print() сохраняются при компиляции, а выводятся, только если для запроса
+		// задан обработчик (WithPrintLogger или EvalPrint). Без обработчика print ничего не делает.
+		rego.EnablePrintStatements(true),
+	}
+
+	for _, module := range e.modules {
+		opts = append(opts, rego.Module(module.Name, module.Source))
+		writeRevision(revision, module.Name, []byte(module.Source))
+	}
+
+	if len(e.paths) > 0 {
+		// Файлы загружаются тем же загрузчиком, что использует rego.Load,
+		// но заранее: rego.Load нельзя совместить с собственным хранилищем данных.
+		// Фильтр исключает тесты политик и файлы с данными.
+		res, err := loader.NewFileLoader().Filtered(e.paths, policyFilesOnly)
+		if err != nil {
+			return nil, err
+		}
+
+		// Модули перебираются в порядке имен, чтобы ревизия не зависела от порядка обхода map
+		names := make([]string, 0, len(res.Modules))
+		for name := range res.Modules {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			file := res.Modules[name]
+			opts = append(opts, rego.ParsedModule(file.Parsed))
+			writeRevision(revision, file.Name, file.Raw)
+		}
+	}
+
+	if e.hasData() {
+		// Данные читаются заново при каждой компиляции, поэтому Reload подхватывает и их изменения
+		docs, err := e.loadData(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		// Ключи объектов сериализуются в отсортированном порядке, поэтому ревизия не зависит от порядка загрузки
+		raw, err := json.Marshal(docs)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка сериализации данных: %w", err)
+		}
+		writeRevision(revision, "data", raw)
+
+		store, err := newStore(ctx, docs)
+		if err != nil {
+			return nil, err
+		}
+
+		opts = append(opts, rego.Store(store))
+	}
+
+	return opts, nil
+}
+
+// inputDocument сериализует структуру входных данных в JSON-документ, чтобы проверка по схеме,
+// вычисление и журнал решений работали с одним и тем же документом. Числа сохраняются как json.Number.
+func inputDocument(input interface{}) (interface{}, error) {
+	switch input.(type) {
+	case nil, map[string]interface{}:
+		return input, nil
+	}
+
+	raw, err := json.Marshal(input)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка сериализации входных данных: %w", err)
+	}
+
+	var doc interface{}
+	if err = util.UnmarshalJSON(raw, &doc); err != nil {
+		return nil, fmt.Errorf("ошибка сериализации входных данных: %w", err)
+	}
+
+	return doc, nil
+}
+
+// writeRevision добавляет в хеш ревизии имя и содержимое модуля или данных
+func writeRevision(revision hash.Hash, name string, content []byte) {
+	revision.Write([]byte(name))
+	revision.Write([]byte{0})
+	revision.Write(content)
+	revision.Write([]byte{0})
+}
+
+// policyFilesOnly фильтр для загрузчика, который исключает тесты политик и файлы с данными
+func policyFilesOnly(_ string, info fs.FileInfo, _ int) bool {
+	if info.IsDir() {
+		return false
+	}
+
+	name := info.Name()
+	return !strings.HasSuffix(name, ".rego") || strings.HasSuffix(name, "_test.rego")
+}
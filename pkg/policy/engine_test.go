@@ -0,0 +1,84 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+const authzModule = `package authz
+
+default allow = false
+
+allow {
+	input.role == "admin"
+}
+
+allow {
+	input.role == "manager"
+	input.experience_years > 5
+}
+`
+
+// TestEngineEval проверяет, что подготовленный запрос можно вызывать конкурентно, в том числе во время Reload.
+// Гонки обнаруживаются при запуске с -race.
+func TestEngineEval(t *testing.T) {
+	ctx := context.Background()
+	engine := New("data.authz.allow", WithModule("authz.rego", authzModule))
+
+	tests := []struct {
+		input map[string]interface{}
+		want  bool
+	}{
+		{input: map[string]interface{}{"role": "admin"}, want: true},
+		{input: map[string]interface{}{"role": "manager", "experience_years": 10}, want: true},
+		{input: map[string]interface{}{"role": "manager", "experience_years": 1}, want: false},
+		{input: map[string]interface{}{"role": "employee"}, want: false},
+	}
+
+	const goroutines = 16
+
+	var wg sync.WaitGroup
+	errs := make(chan error, goroutines*len(tests))
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+
+			// Часть горутин перекомпилирует политику, пока остальные вычисляют запросы
+			if g%4 == 0 {
+				if err := engine.Reload(ctx); err != nil {
+					errs <- err
+				}
+			}
+
+			for _, tt := range tests {
+				decision, err := engine.Eval(ctx, tt.input)
+				if err != nil {
+					errs <- err
+					continue
+				}
+
+				got, err := decision.Bool()
+				if err != nil {
+					errs <- err
+					continue
+				}
+				if got != tt.want {
+					errs <- fmt.Errorf("input %v: получено %v, ожидалось %v", tt.input, got, tt.want)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+
+	if engine.Revision() == "" {
+		t.Error("ревизия пустая после компиляции")
+	}
+}
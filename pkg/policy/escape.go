@@ -0,0 +1,208 @@
+package policy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"unicode/utf8"
+)
+
+// jsonNumber грамматика числа JSON, которая совпадает с грамматикой числа в rego
+var jsonNumber = regexp.MustCompile(`^-?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][+-]?[0-9]+)?$`)
+
+// escapeFuncs имена функций, которые выводят значение в виде литерала rego
+var escapeFuncs = map[string]bool{
+	"regoString": true,
+	"regoSet":    true,
+	"regoNumber": true,
+}
+
+// TemplateFuncs функции для безопасной подстановки значений в шаблоны политик.
+// Каждая функция выводит готовый литерал rego, поэтому значение подставляется без кавычек:
+//
+//	"source_slug": {{ regoString .SourceSlug }},
+//	required_permissions := {{ regoSet .RequiredPermissions }}
+//	max_age := {{ regoNumber .MaxAge }}
+//
+// Какие бы символы ни содержало значение, оно остается одним литералом и не меняет структуру политики.
+func TemplateFuncs() template.FuncMap {
+	return template.FuncMap{
+		"regoString": regoString,
+		"regoSet":    regoSet,
+		"regoNumber": regoNumber,
+	}
+}
+
+// regoString выводит строку как строковый литерал rego в двойных кавычках.
+// Экранирование строк в rego совпадает с JSON. Строка с некорректным UTF-8 отклоняется:
+// при кодировании такие байты молча заменились бы на U+FFFD, и значение в политике отличалось бы от данных.
+func regoString(s string) (string, error) {
+	if !utf8.ValidString(s) {
+		return "", fmt.Errorf("regoString: %q содержит некорректный UTF-8", s)
+	}
+
+	var buf bytes.Buffer
+
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(s); err != nil {
+		return "", err
+	}
+
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
+
+// regoNumber выводит число как числовой литерал rego.
+// Принимает целые и дробные числа Go, json.Number и строки, содержащие число.
+func regoNumber(v interface{}) (string, error) {
+	switch n := v.(type) {
+	case json.Number:
+		return regoNumberString(string(n))
+	case string:
+		return regoNumberString(n)
+	case float32:
+		return regoFloat(float64(n), 32)
+	case float64:
+		return regoFloat(n, 64)
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(rv.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(rv.Uint(), 10), nil
+	default:
+		return "", fmt.Errorf("regoNumber: ожидалось число, получен %T", v)
+	}
+}
+
+func regoNumberString(s string) (string, error) {
+	if !jsonNumber.MatchString(s) {
+		return "", fmt.Errorf("regoNumber: %q не является числом", s)
+	}
+
+	return s, nil
+}
+
+func regoFloat(f float64, bitSize int) (string, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return "", fmt.Errorf("regoNumber: %v не может быть представлено в rego", f)
+	}
+
+	return strconv.FormatFloat(f, 'g', -1, bitSize), nil
+}
+
+// regoSet выводит срез или массив как множество rego. Элементами могут быть строки, числа и bool.
+// Пустое множество выводится как set(), так как {} в rego - пустой объект.
+func regoSet(values interface{}) (string, error) {
+	rv := reflect.ValueOf(values)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return "", fmt.Errorf("regoSet: ожидался срез, получен %T", values)
+	}
+
+	if rv.Len() == 0 {
+		return "set()", nil
+	}
+
+	items := make([]string, 0, rv.Len())
+	for i := 0; i < rv.Len(); i++ {
+		item, err := regoScalar(rv.Index(i).Interface())
+		if err != nil {
+			return "", fmt.Errorf("regoSet: элемент %d: %w", i, err)
+		}
+
+		items = append(items, item)
+	}
+
+	return "{" + strings.Join(items, ", ") + "}", nil
+}
+
+func regoScalar(v interface{}) (string, error) {
+	switch s := v.(type) {
+	case string:
+		return regoString(s)
+	case bool:
+		return strconv.FormatBool(s), nil
+	default:
+		return regoNumber(v)
+	}
+}
+
+// checkStrict проверяет, что каждое значение, которое выводит шаблон, проходит через функцию экранирования.
+// Подстановка вида "{{ .SourceSlug }}" отклоняется: значение с кавычкой или скобкой могло бы изменить политику.
+func checkStrict(tmpl *template.Template) error {
+	for _, t := range tmpl.Templates() {
+		if t.Tree == nil {
+			continue
+		}
+
+		if err := checkStrictNode(t.Tree, t.Tree.Root); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func checkStrictNode(tree *parse.Tree, node parse.Node) error {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return nil
+		}
+
+		for _, child := range n.Nodes {
+			if err := checkStrictNode(tree, child); err != nil {
+				return err
+			}
+		}
+	case *parse.ActionNode:
+		// Присваивание переменной ничего не выводит
+		if len(n.Pipe.Decl) > 0 || escaped(n.Pipe) {
+			return nil
+		}
+
+		location, _ := tree.ErrorContext(n)
+		return fmt.Errorf("%s: значение %s выводится без экранирования, используйте regoString, regoSet или regoNumber",
+			location, n)
+	case *parse.IfNode:
+		return checkStrictBranch(tree, &n.BranchNode)
+	case *parse.RangeNode:
+		return checkStrictBranch(tree, &n.BranchNode)
+	case *parse.WithNode:
+		return checkStrictBranch(tree, &n.BranchNode)
+	}
+
+	return nil
+}
+
+func checkStrictBranch(tree *parse.Tree, branch *parse.BranchNode) error {
+	if err := checkStrictNode(tree, branch.List); err != nil {
+		return err
+	}
+
+	return checkStrictNode(tree, branch.ElseList)
+}
+
+// escaped сообщает, что последняя команда конвейера - функция экранирования, например {{ .SourceSlug | regoString }}
+func escaped(pipe *parse.PipeNode) bool {
+	if pipe == nil || len(pipe.Cmds) == 0 {
+		return false
+	}
+
+	cmd := pipe.Cmds[len(pipe.Cmds)-1]
+	if len(cmd.Args) == 0 {
+		return false
+	}
+
+	ident, ok := cmd.Args[0].(*parse.IdentifierNode)
+	return ok && escapeFuncs[ident.Ident]
+}
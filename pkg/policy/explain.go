@@ -0,0 +1,465 @@
+package policy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/open-policy-agent/opa/rego"
+	"github.com/open-policy-agent/opa/topdown"
+)
+
+// Explanation решение вместе с деревом правил, вычисленных для него
+type Explanation struct {
+	Decision Decision `json:"decision"`
+	// Trace корень дерева - запрос движка, дочерние узлы - правила в порядке вычисления
+	Trace *TraceNode `json:"trace"`
+}
+
+// TraceNode правило, тело которого вычислялось. Одно правило может встречаться несколько раз:
+// у правила с несколькими телами каждое тело - отдельный узел, а функция - узел на каждый вызов.
+type TraceNode struct {
+	// Rule полный путь правила, например data.resource_check.resourceCondition. Для корня - запрос движка.
+	Rule string `json:"rule"`
+	// Default правило по-умолчанию (default)
+	Default bool `json:"default,omitempty"`
+	// Location файл и строка правила
+	Location string `json:"location,omitempty"`
+	// Passed тело правила выполнилось
+	Passed bool `json:"passed"`
+	// Failed невыполнившиеся выражения тела правила и вложенных в него not и comprehension
+	Failed []FailedExpr `json:"failed,omitempty"`
+	// Children правила, вычисленные при вычислении этого правила
+	Children []*TraceNode `json:"children,omitempty"`
+}
+
+// FailedExpr невыполнившееся выражение
+type FailedExpr struct {
+	// Expr выражение в том виде, в каком оно записано в политике
+	Expr string `json:"expr"`
+	// Location файл и строка выражения
+	Location string `json:"location,omitempty"`
+	// Bindings значения переменных и ссылок на input и data из выражения в момент, когда оно не выполнилось
+	Bindings []Binding `json:"bindings,omitempty"`
+}
+
+// Binding значение переменной или ссылки в выражении
+type Binding struct {
+	Name  string      `json:"name"`
+	Value interface{} `json:"value"`
+}
+
+// evalTracer включает трассировку одного вычисления
+func evalTracer(tracer topdown.QueryTracer) EvalOption {
+	return func(o *evalOptions) {
+		o.tracer = tracer
+	}
+}
+
+// Explain вычисляет запрос так же, как Eval, но с трассировкой OPA, и возвращает вместе с решением дерево
+// вычисленных правил: какие тела правил выполнились, какие выражения в них не выполнились и с какими значениями.
+// Explain заметно дороже Eval: трассировка замедляет вычисление, а значения ссылок в невыполнившихся выражениях
+// вычисляются отдельным запросом, поэтому он предназначен для отладки отказов, а не для каждого запроса.
+func (e *Engine) Explain(ctx context.Context, input interface{}, opts ...EvalOption) (Explanation, error) {
+	tracer := topdown.NewBufferTracer()
+
+	decision, err := e.Eval(ctx, input, append(opts, evalTracer(tracer))...)
+	if err != nil {
+		return Explanation{}, err
+	}
+
+	compiled, err := e.prepare(ctx)
+	if err != nil {
+		return Explanation{}, err
+	}
+
+	doc, err := inputDocument(input)
+	if err != nil {
+		return Explanation{}, err
+	}
+
+	b := newTraceBuilder(e.query)
+	for _, event := range *tracer {
+		b.add(event)
+	}
+
+	if err = b.resolveRefs(ctx, compiled.opts, doc); err != nil {
+		return Explanation{}, err
+	}
+
+	return Explanation{
+		Decision: decision,
+		Trace:    b.root,
+	}, nil
+}
+
+// traceBuilder собирает дерево правил из событий трассировки OPA.
+// Каждое тело правила и каждый вложенный запрос (not, comprehension) - отдельный запрос с QueryID и ParentID.
+// Узлы дерева создаются только для тел правил, а вложенные запросы относятся к правилу, в котором они записаны.
+type traceBuilder struct {
+	root *TraceNode
+	// nodes узел правила для каждого запроса
+	nodes map[uint64]*TraceNode
+	// seen невыполнившиеся выражения узла, чтобы при переборе не повторять одно и то же выражение
+	seen map[*TraceNode]map[string]bool
+	// refs ссылки на input и data из невыполнившихся выражений, значения которых нужно вычислить
+	refs []pendingRef
+}
+
+// pendingRef ссылка из невыполнившегося выражения, значение которой вычисляется после трассировки
+type pendingRef struct {
+	node *TraceNode
+	expr int
+	ref  ast.Ref
+}
+
+func newTraceBuilder(query string) *traceBuilder {
+	return &traceBuilder{
+		root:  &TraceNode{Rule: query},
+		nodes: make(map[uint64]*TraceNode),
+		seen:  make(map[*TraceNode]map[string]bool),
+	}
+}
+
+func (b *traceBuilder) add(event *topdown.Event) {
+	switch event.Op {
+	case topdown.EnterOp:
+		b.enter(event)
+	case topdown.ExitOp:
+		// Exit тела правила означает, что тело выполнилось. Exit вложенных запросов не меняет узел.
+		if _, ok := event.Node.(*ast.Rule); ok {
+			if node := b.nodes[event.QueryID]; node != nil {
+				node.Passed = true
+			}
+		}
+		if event.QueryID == 0 {
+			b.root.Passed = true
+		}
+	case topdown.FailOp:
+		if expr, ok := event.Node.(*ast.Expr); ok {
+			b.fail(b.node(event.QueryID), expr, event)
+		}
+	}
+}
+
+func (b *traceBuilder) enter(event *topdown.Event) {
+	parent := b.node(event.ParentID)
+
+	rule, ok := event.Node.(*ast.Rule)
+	if !ok {
+		// Вложенный запрос относится к правилу, в котором он записан
+		if event.QueryID != 0 {
+			b.nodes[event.QueryID] = parent
+		} else {
+			b.nodes[0] = b.root
+		}
+		return
+	}
+
+	node := &TraceNode{
+		Rule:     rulePath(rule),
+		Default:  rule.Default,
+		Location: location(rule.Location),
+	}
+	parent.Children = append(parent.Children, node)
+	b.nodes[event.QueryID] = node
+}
+
+// node узел правила для запроса. Запросы, для которых не было Enter, относятся к корню.
+func (b *traceBuilder) node(queryID uint64) *TraceNode {
+	if node := b.nodes[queryID]; node != nil {
+		return node
+	}
+
+	return b.root
+}
+
+func (b *traceBuilder) fail(node *TraceNode, expr *ast.Expr, event *topdown.Event) {
+	failed := FailedExpr{
+		Expr:     exprText(expr),
+		Location: location(expr.Location),
+		Bindings: varBindings(expr, event),
+	}
+
+	key := failed.Location + failed.Expr + fmt.Sprint(failed.Bindings)
+	if b.seen[node] == nil {
+		b.seen[node] = make(map[string]bool)
+	}
+	if b.seen[node][key] {
+		return
+	}
+	b.seen[node][key] = true
+
+	node.Failed = append(node.Failed, failed)
+
+	// Ссылки на input и data не связаны с переменными, поэтому их значения вычисляются отдельно.
+	// Переменные в ссылке заменяются значениями, с которыми выражение не выполнилось.
+	seen := make(map[string]bool)
+	for _, ref := range exprRefs(expr) {
+		if len(ref) < 2 || !(ref.HasPrefix(ast.InputRootRef) || ref.HasPrefix(ast.DefaultRootRef)) {
+			continue
+		}
+
+		plugged, err := ast.TransformVars(ref.Copy(), func(v ast.Var) (ast.Value, error) {
+			if value := event.Locals.Get(v); value != nil {
+				return value, nil
+			}
+			return v, nil
+		})
+		if err != nil {
+			continue
+		}
+
+		pluggedRef := plugged.(ast.Ref)
+		if !pluggedRef.IsGround() || seen[pluggedRef.String()] {
+			continue
+		}
+		seen[pluggedRef.String()] = true
+
+		b.refs = append(b.refs, pendingRef{node: node, expr: len(node.Failed) - 1, ref: pluggedRef})
+	}
+}
+
+// exprRefs ссылки в выражении, включая вложенные, без имен вызываемых функций
+func exprRefs(expr *ast.Expr) []ast.Ref {
+	var refs []ast.Ref
+
+	var vis *ast.GenericVisitor
+	vis = ast.NewGenericVisitor(func(x interface{}) bool {
+		switch x := x.(type) {
+		case *ast.Expr:
+			if x.IsCall() {
+				for _, operand := range x.Operands() {
+					vis.Walk(operand)
+				}
+				return true
+			}
+		case ast.Call:
+			for _, arg := range x[1:] {
+				vis.Walk(arg)
+			}
+			return true
+		case ast.Ref:
+			refs = append(refs, x)
+		}
+
+		return false
+	})
+	vis.Walk(expr)
+
+	return refs
+}
+
+// resolveRefs вычисляет значения ссылок из невыполнившихся выражений одним запросом к тем же политикам и данным.
+// Неопределенная ссылка (например, незарегистрированный ресурс) получает значение undefined.
+func (b *traceBuilder) resolveRefs(ctx context.Context, opts []func(*rego.Rego), input interface{}) error {
+	if len(b.refs) == 0 {
+		return nil
+	}
+
+	// Каждая ссылка оборачивается в comprehension, чтобы неопределенная ссылка не делала неопределенным весь запрос
+	terms := make([]string, 0, len(b.refs))
+	for _, ref := range b.refs {
+		terms = append(terms, fmt.Sprintf("[x | x := %s]", ref.ref))
+	}
+
+	evalOpts := append(append([]func(*rego.Rego){}, opts...), rego.Query("["+strings.Join(terms, ", ")+"]"))
+	if input != nil {
+		evalOpts = append(evalOpts, rego.Input(input))
+	}
+
+	rs, err := rego.New(evalOpts...).Eval(ctx)
+	if err != nil {
+		return fmt.Errorf("ошибка при вычислении значений ссылок: %w", err)
+	}
+	if len(rs) == 0 || len(rs[0].Expressions) == 0 {
+		return nil
+	}
+
+	values, _ := rs[0].Expressions[0].Value.([]interface{})
+	for i, ref := range b.refs {
+		binding := Binding{Name: ref.ref.String(), Value: "undefined"}
+		if i < len(values) {
+			if found, _ := values[i].([]interface{}); len(found) > 0 {
+				binding.Value = found[0]
+			}
+		}
+
+		failed := &ref.node.Failed[ref.expr]
+		failed.Bindings = append(failed.Bindings, binding)
+	}
+
+	return nil
+}
+
+// varBindings значения переменных выражения. Переменные, созданные компилятором OPA, выводятся
+// под именами из политики, а служебные переменные без имени в политике пропускаются.
+func varBindings(expr *ast.Expr, event *topdown.Event) []Binding {
+	if event.Locals == nil {
+		return nil
+	}
+
+	var bindings []Binding
+	seen := make(map[string]bool)
+	ast.WalkVars(expr, func(v ast.Var) bool {
+		value := event.Locals.Get(v)
+		if value == nil {
+			return false
+		}
+
+		name := string(v)
+		if meta, ok := event.LocalMetadata[v]; ok {
+			name = string(meta.Name)
+		}
+		if v.IsWildcard() {
+			return false
+		}
+		// Переменные, созданные компилятором, хранят результаты вложенных вызовов, например count(...).
+		// Выводятся только их скалярные значения: в объектах обычно лежит переданный аргумент целиком.
+		if ast.Var(name).IsGenerated() {
+			if !ast.IsScalar(value) {
+				return false
+			}
+			name = "промежуточное значение"
+		}
+
+		if seen[name] {
+			return false
+		}
+		seen[name] = true
+
+		native, err := ast.JSON(value)
+		if err != nil {
+			native = value.String()
+		}
+		bindings = append(bindings, Binding{Name: name, Value: native})
+
+		return false
+	})
+
+	sort.Slice(bindings, func(i, j int) bool {
+		return bindings[i].Name < bindings[j].Name
+	})
+
+	return bindings
+}
+
+// rulePath полный путь правила с пакетом
+func rulePath(rule *ast.Rule) string {
+	if rule.Module == nil {
+		return rule.Head.Ref().String()
+	}
+
+	return rule.Ref().String()
+}
+
+// exprText выражение в том виде, в каком оно записано в политике, в одну строку
+func exprText(expr *ast.Expr) string {
+	if expr.Location != nil && len(expr.Location.Text) > 0 {
+		return strings.Join(strings.Fields(string(expr.Location.Text)), " ")
+	}
+
+	return expr.String()
+}
+
+func location(loc *ast.Location) string {
+	if loc == nil {
+		return ""
+	}
+
+	return fmt.Sprintf("%s:%d", loc.File, loc.Row)
+}
+
+// String выводит дерево правил текстом: ✓ - тело правила выполнилось, ✗ - нет.
+// Правила, под которыми нет невыполнившихся выражений, выводятся одной строкой без вложенных правил.
+func (x Explanation) String() string {
+	var sb strings.Builder
+
+	status := "результат не определен"
+	if x.Decision.Defined {
+		status = "результат определен"
+	}
+	fmt.Fprintf(&sb, "Запрос %s: %s\n", x.Decision.Query, status)
+
+	if x.Trace != nil {
+		for _, child := range x.Trace.Children {
+			writeTraceNode(&sb, child, 0)
+		}
+		writeFailed(&sb, x.Trace.Failed, 0)
+	}
+
+	return sb.String()
+}
+
+func writeTraceNode(sb *strings.Builder, node *TraceNode, depth int) {
+	indent := strings.Repeat("  ", depth)
+
+	mark := "✗"
+	if node.Passed {
+		mark = "✓"
+	}
+
+	name := node.Rule
+	if node.Default {
+		name += " (default)"
+	}
+
+	fmt.Fprintf(sb, "%s%s %s", indent, mark, name)
+	if node.Location != "" {
+		fmt.Fprintf(sb, "  %s", node.Location)
+	}
+	sb.WriteString("\n")
+
+	if !node.hasFailures() {
+		return
+	}
+
+	writeFailed(sb, node.Failed, depth+1)
+	for _, child := range node.Children {
+		writeTraceNode(sb, child, depth+1)
+	}
+}
+
+func writeFailed(sb *strings.Builder, failed []FailedExpr, depth int) {
+	indent := strings.Repeat("  ", depth)
+
+	for _, f := range failed {
+		fmt.Fprintf(sb, "%sне выполнено: %s", indent, f.Expr)
+		if f.Location != "" {
+			fmt.Fprintf(sb, "  %s", f.Location)
+		}
+		sb.WriteString("\n")
+
+		for _, binding := range f.Bindings {
+			fmt.Fprintf(sb, "%s    %s = %s\n", indent, binding.Name, formatBinding(binding.Value))
+		}
+	}
+}
+
+// hasFailures сообщает, что в правиле или во вложенных в него правилах есть невыполнившиеся выражения
+func (n *TraceNode) hasFailures() bool {
+	if len(n.Failed) > 0 {
+		return true
+	}
+
+	for _, child := range n.Children {
+		if child.hasFailures() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// formatBinding значение в JSON, чтобы строки отличались от чисел и массивов
+func formatBinding(value interface{}) string {
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+
+	return string(raw)
+}
@@ -0,0 +1,303 @@
+package policy
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+const explainModule = `package authz
+
+default allow = false
+
+allow {
+	input.level > 2
+}
+
+allow {
+	data.resources[input.resource].owner == input.user
+}
+
+allow {
+	lvl := data.levels[input.resource]
+	input.level >= lvl
+}
+
+allow {
+	p := input.permissions[_]
+	p == "write"
+}
+`
+
+func newExplainEngine() *Engine {
+	return New("data.authz.allow",
+		WithModule("authz.rego", explainModule),
+		WithData(map[string]interface{}{
+			"levels":    map[string]interface{}{"docs": 3},
+			"resources": map[string]interface{}{"docs": map[string]interface{}{"owner": "bob"}},
+		}),
+	)
+}
+
+func explain(t *testing.T, input map[string]interface{}) Explanation {
+	t.Helper()
+
+	explanation, err := newExplainEngine().Explain(context.Background(), input)
+	if err != nil {
+		t.Fatalf("Explain: %v", err)
+	}
+
+	return explanation
+}
+
+// bodies узлы тел правил по строке, на которой начинается тело. Порядок вычисления тел задает OPA.
+func bodies(t *testing.T, explanation Explanation) map[string]*TraceNode {
+	t.Helper()
+
+	nodes := make(map[string]*TraceNode)
+	for _, child := range explanation.Trace.Children {
+		if child.Rule != "data.authz.allow" {
+			t.Errorf("правило %s, ожидалось data.authz.allow", child.Rule)
+		}
+		nodes[child.Location] = child
+	}
+
+	return nodes
+}
+
+func TestExplainRuleTree(t *testing.T) {
+	tests := []struct {
+		name  string
+		input map[string]interface{}
+		value bool
+		// passed тела правил, которые должны быть в дереве. Остальные тела, если есть, не выполнились.
+		passed map[string]bool
+	}{
+		{
+			name:  "выполнилось одно тело",
+			input: map[string]interface{}{"user": "bob", "level": 1, "resource": "docs", "permissions": []interface{}{}},
+			value: true,
+			// После выполнившегося тела OPA может не вычислять остальные
+			passed: map[string]bool{"authz.rego:9": true},
+		},
+		{
+			name:  "выполнилось только правило по-умолчанию",
+			input: map[string]interface{}{"user": "alice", "level": 1, "resource": "docs", "permissions": []interface{}{}},
+			value: false,
+			passed: map[string]bool{
+				"authz.rego:3":  true,
+				"authz.rego:5":  false,
+				"authz.rego:9":  false,
+				"authz.rego:13": false,
+				"authz.rego:18": false,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			explanation := explain(t, tt.input)
+
+			if explanation.Decision.Value != tt.value {
+				t.Errorf("решение %v, ожидалось %v", explanation.Decision.Value, tt.value)
+			}
+			if explanation.Trace.Rule != "data.authz.allow" || !explanation.Trace.Passed {
+				t.Errorf("корень %+v", explanation.Trace)
+			}
+
+			nodes := bodies(t, explanation)
+			for location, want := range tt.passed {
+				if node := nodes[location]; node == nil || node.Passed != want {
+					t.Errorf("%s: узел %+v, ожидалось passed %v", location, node, want)
+				}
+			}
+
+			for location, node := range nodes {
+				if _, ok := tt.passed[location]; !ok && node.Passed {
+					t.Errorf("%s: тело правила выполнилось", location)
+				}
+				if node.Default != (location == "authz.rego:3") {
+					t.Errorf("%s: default %v", location, node.Default)
+				}
+				if node.Passed && len(node.Failed) > 0 {
+					t.Errorf("%s: у выполнившегося тела есть невыполнившиеся выражения %+v", location, node.Failed)
+				}
+			}
+		})
+	}
+}
+
+func TestExplainFailedExpr(t *testing.T) {
+	tests := []struct {
+		name     string
+		resource string
+		body     string
+		want     []FailedExpr
+	}{
+		{
+			name:     "ссылки на data и input",
+			resource: "docs",
+			body:     "authz.rego:9",
+			want: []FailedExpr{{
+				Expr:     "data.resources[input.resource].owner == input.user",
+				Location: "authz.rego:10",
+				Bindings: []Binding{
+					{Name: "промежуточное значение", Value: "docs"},
+					{Name: "data.resources.docs.owner", Value: "bob"},
+					{Name: "input.user", Value: "alice"},
+				},
+			}},
+		},
+		{
+			name:     "переменная",
+			resource: "docs",
+			body:     "authz.rego:13",
+			want: []FailedExpr{{
+				Expr:     "input.level >= lvl",
+				Location: "authz.rego:15",
+				Bindings: []Binding{
+					{Name: "lvl", Value: json.Number("3")},
+					{Name: "промежуточное значение", Value: json.Number("1")},
+				},
+			}},
+		},
+		{
+			name:     "незарегистрированный ресурс",
+			resource: "nope",
+			body:     "authz.rego:13",
+			want: []FailedExpr{{
+				Expr:     "lvl := data.levels[input.resource]",
+				Location: "authz.rego:14",
+				Bindings: []Binding{
+					{Name: "промежуточное значение", Value: "nope"},
+					{Name: "data.levels.nope", Value: "undefined"},
+				},
+			}},
+		},
+		{
+			name:     "незарегистрированный ресурс в сравнении",
+			resource: "nope",
+			body:     "authz.rego:9",
+			want: []FailedExpr{{
+				Expr:     "data.resources[input.resource].owner == input.user",
+				Location: "authz.rego:10",
+				Bindings: []Binding{
+					{Name: "промежуточное значение", Value: "nope"},
+					{Name: "data.resources.nope.owner", Value: "undefined"},
+					{Name: "input.user", Value: "alice"},
+				},
+			}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			explanation := explain(t, map[string]interface{}{
+				"user": "alice", "level": 1, "resource": tt.resource, "permissions": []interface{}{},
+			})
+
+			node := bodies(t, explanation)[tt.body]
+			if node == nil {
+				t.Fatalf("нет тела правила %s", tt.body)
+			}
+			if !reflect.DeepEqual(node.Failed, tt.want) {
+				t.Errorf("невыполнившиеся выражения\n%+v\nожидались\n%+v", node.Failed, tt.want)
+			}
+		})
+	}
+}
+
+// TestExplainFailedDedup проверяет, что при переборе одно и то же выражение с теми же значениями выводится один раз
+func TestExplainFailedDedup(t *testing.T) {
+	explanation := explain(t, map[string]interface{}{
+		"user": "alice", "level": 1, "resource": "docs", "permissions": []interface{}{"read", "read", "delete", "read"},
+	})
+
+	node := bodies(t, explanation)["authz.rego:18"]
+	if node == nil {
+		t.Fatal("нет тела правила authz.rego:18")
+	}
+
+	want := []FailedExpr{
+		{Expr: `p == "write"`, Location: "authz.rego:20", Bindings: []Binding{{Name: "p", Value: "read"}}},
+		{Expr: `p == "write"`, Location: "authz.rego:20", Bindings: []Binding{{Name: "p", Value: "delete"}}},
+	}
+	if !reflect.DeepEqual(node.Failed, want) {
+		t.Errorf("невыполнившиеся выражения\n%+v\nожидались\n%+v", node.Failed, want)
+	}
+}
+
+func TestExplanationString(t *testing.T) {
+	explanation := Explanation{
+		Decision: Decision{Query: "data.authz.allow", Defined: true, Value: false},
+		Trace: &TraceNode{
+			Rule:   "data.authz.allow",
+			Passed: true,
+			Children: []*TraceNode{
+				{
+					Rule:     "data.authz.allow",
+					Location: "authz.rego:9",
+					Failed: []FailedExpr{{
+						Expr:     "data.resources[input.resource].owner == input.user",
+						Location: "authz.rego:10",
+						Bindings: []Binding{
+							{Name: "data.resources.nope.owner", Value: "undefined"},
+							{Name: "input.user", Value: "alice"},
+						},
+					}},
+				},
+				{
+					Rule:     "data.authz.allow",
+					Location: "authz.rego:13",
+					Children: []*TraceNode{
+						{
+							Rule:     "data.authz.owner",
+							Location: "authz.rego:25",
+							Failed:   []FailedExpr{{Expr: "input.level > 2", Location: "authz.rego:26", Bindings: []Binding{{Name: "input.level", Value: json.Number("1")}}}},
+						},
+					},
+				},
+				{
+					// Под правилом без невыполнившихся выражений вложенные правила не выводятся
+					Rule:     "data.authz.allow",
+					Location: "authz.rego:18",
+					Passed:   true,
+					Children: []*TraceNode{{Rule: "data.authz.roles", Passed: true}},
+				},
+				{Rule: "data.authz.allow", Default: true, Location: "authz.rego:3", Passed: true},
+			},
+		},
+	}
+
+	want := `Запрос data.authz.allow: результат определен
+✗ data.authz.allow  authz.rego:9
+  не выполнено: data.resources[input.resource].owner == input.user  authz.rego:10
+      data.resources.nope.owner = "undefined"
+      input.user = "alice"
+✗ data.authz.allow  authz.rego:13
+  ✗ data.authz.owner  authz.rego:25
+    не выполнено: input.level > 2  authz.rego:26
+        input.level = 1
+✓ data.authz.allow  authz.rego:18
+✓ data.authz.allow (default)  authz.rego:3
+`
+	if got := explanation.String(); got != want {
+		t.Errorf("String():\n%s\nожидалось:\n%s", got, want)
+	}
+
+	undefined := Explanation{
+		Decision: Decision{Query: "data.authz.allow"},
+		Trace: &TraceNode{
+			Rule:   "data.authz.allow",
+			Failed: []FailedExpr{{Expr: "data.authz.allow", Bindings: []Binding{{Name: "data.authz.allow", Value: "undefined"}}}},
+		},
+	}
+	want = `Запрос data.authz.allow: результат не определен
+не выполнено: data.authz.allow
+    data.authz.allow = "undefined"
+`
+	if got := undefined.String(); got != want {
+		t.Errorf("String():\n%s\nожидалось:\n%s", got, want)
+	}
+}
@@ -0,0 +1,524 @@
+package policy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/open-policy-agent/opa/rego"
+	"github.com/open-policy-agent/opa/util"
+)
+
+// Filter условие на неизвестные поля входных данных, при котором запрос движка выполняется.
+// Условие получается частичным вычислением политики (Engine.Filter) и переводится в SQL WHERE (SQL)
+// или проверяется в Go (Match), например чтобы выбрать из таблицы ресурсы, доступные пользователю.
+type Filter struct {
+	root filterNode
+}
+
+// Always условие выполняется для любых значений неизвестных полей
+func (f Filter) Always() bool {
+	and, ok := f.root.(andNode)
+	return ok && len(and) == 0
+}
+
+// Never условие не выполняется ни для каких значений неизвестных полей, например у пользователя нет прав на действие
+func (f Filter) Never() bool {
+	or, ok := f.root.(orNode)
+	return ok && len(or) == 0
+}
+
+// SQL переводит условие в выражение для WHERE с плейсхолдерами ? и аргументами к ним.
+// Поле становится колонкой с тем же именем. Отсутствующее поле соответствует NULL.
+func (f Filter) SQL() (string, []interface{}) {
+	var args []interface{}
+	where := f.root.sql(&args)
+
+	return where, args
+}
+
+// Match проверяет условие для значений полей, например строки, прочитанной из базы.
+// Отсутствующее поле и nil означают, что поле не передано.
+func (f Filter) Match(fields map[string]interface{}) bool {
+	return f.root.match(fields)
+}
+
+// Filter частично вычисляет запрос движка: поля unknowns (например input.source_uuid) считаются неизвестными,
+// а остальное вычисляется по input. Запрос должен быть булевым правилом, например data.final_check.accessAllowed.
+// Результат - условие на неизвестные поля, при котором правило выполняется.
+// Поддерживаются сравнения неизвестного поля со значением (==, !=, <, <=, >, >=), object.get с полем и значением
+// по-умолчанию и булевы правила, в том числе с default false и not. Для остальных выражений, в том числе с with,
+// и для правил с else возвращается ошибка.
+// Входные данные не проверяются по схеме, так как в них нет неизвестных полей.
+func (e *Engine) Filter(ctx context.Context, input interface{}, unknowns ...string) (Filter, error) {
+	fields := make(map[string]bool, len(unknowns))
+	for _, unknown := range unknowns {
+		field, err := unknownField(unknown)
+		if err != nil {
+			return Filter{}, err
+		}
+		fields[field] = true
+	}
+
+	compiled, err := e.prepare(ctx)
+	if err != nil {
+		return Filter{}, err
+	}
+
+	doc, err := inputDocument(input)
+	if err != nil {
+		return Filter{}, err
+	}
+
+	opts := append(append([]func(*rego.Rego){}, compiled.opts...),
+		rego.Query(e.query),
+		rego.Unknowns(unknowns),
+		// print() в остаточных условиях не переводится в SQL
+		rego.EnablePrintStatements(false),
+	)
+	if doc != nil {
+		opts = append(opts, rego.Input(doc))
+	}
+
+	pq, err := rego.New(opts...).Partial(ctx)
+	if err != nil {
+		return Filter{}, fmt.Errorf("ошибка при частичном вычислении политики: %w", err)
+	}
+
+	t := translator{
+		fields: fields,
+		rules:  make(map[string][]*ast.Rule),
+	}
+	for _, module := range pq.Support {
+		for _, rule := range module.Rules {
+			path := rule.Ref().String()
+			t.rules[path] = append(t.rules[path], rule)
+		}
+	}
+
+	root, err := t.queries(pq.Queries)
+	if err != nil {
+		return Filter{}, err
+	}
+
+	return Filter{root: root}, nil
+}
+
+// unknownField имя поля из неизвестного вида input.<поле>
+func unknownField(unknown string) (string, error) {
+	ref, err := ast.ParseRef(unknown)
+	if err != nil || len(ref) < 2 || !ref.HasPrefix(ast.InputRootRef) {
+		return "", fmt.Errorf("неизвестное поле %q должно иметь вид input.<поле>", unknown)
+	}
+
+	return refField(ref)
+}
+
+// refField имя поля для ссылки input.a.b: a.b
+func refField(ref ast.Ref) (string, error) {
+	parts := make([]string, 0, len(ref)-1)
+	for _, term := range ref[1:] {
+		s, ok := term.Value.(ast.String)
+		if !ok {
+			return "", fmt.Errorf("неподдерживаемая ссылка %v: ожидались только имена полей", ref)
+		}
+		parts = append(parts, string(s))
+	}
+
+	return strings.Join(parts, "."), nil
+}
+
+// translator переводит остаточные запросы частичного вычисления в условие Filter
+type translator struct {
+	// fields неизвестные поля
+	fields map[string]bool
+	// rules правила вспомогательных модулей частичного вычисления по полному пути
+	rules map[string][]*ast.Rule
+}
+
+// queries остаточные запросы - это дизъюнкция: правило выполняется, если выполняется любой из них
+func (t translator) queries(queries []ast.Body) (filterNode, error) {
+	or := make(orNode, 0, len(queries))
+	for _, body := range queries {
+		node, err := t.body(body)
+		if err != nil {
+			return nil, err
+		}
+		or = append(or, node)
+	}
+
+	return or.simplify(), nil
+}
+
+// body выражения запроса - это конъюнкция
+func (t translator) body(body ast.Body) (filterNode, error) {
+	and := make(andNode, 0, len(body))
+	for _, expr := range body {
+		node, err := t.expr(expr)
+		if err != nil {
+			return nil, err
+		}
+		and = append(and, node)
+	}
+
+	return and.simplify(), nil
+}
+
+func (t translator) expr(expr *ast.Expr) (filterNode, error) {
+	// with меняет input или data только для этого выражения, а в условии на поля это не выразить
+	if len(expr.With) > 0 {
+		return nil, unsupported(expr)
+	}
+
+	node, err := t.positive(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	if expr.Negated {
+		return notNode{node: node}, nil
+	}
+
+	return node, nil
+}
+
+// positive переводит выражение без учета not
+func (t translator) positive(expr *ast.Expr) (filterNode, error) {
+	if expr.IsCall() {
+		return t.call(expr)
+	}
+
+	term, ok := expr.Terms.(*ast.Term)
+	if !ok {
+		return nil, unsupported(expr)
+	}
+
+	switch v := term.Value.(type) {
+	case ast.Boolean:
+		if v {
+			return andNode{}, nil
+		}
+		return orNode{}, nil
+	case ast.Ref:
+		return t.rule(v, expr)
+	default:
+		return nil, unsupported(expr)
+	}
+}
+
+// rule подставляет булево правило вспомогательного модуля: оно выполняется, если выполняется любое из его тел
+func (t translator) rule(ref ast.Ref, expr *ast.Expr) (filterNode, error) {
+	rules, ok := t.rules[ref.String()]
+	if !ok {
+		return nil, unsupported(expr)
+	}
+
+	var bodies []ast.Body
+	for _, rule := range rules {
+		// Тела else проверяются, только если не выполнилось предыдущее тело, поэтому их нельзя добавить в дизъюнкцию
+		if rule.Else != nil {
+			return nil, fmt.Errorf("неподдерживаемое правило %v: правила с else не поддерживаются", rule.Head.Ref())
+		}
+
+		value := rule.Head.Value
+		if value == nil {
+			return nil, unsupported(expr)
+		}
+
+		if rule.Default {
+			// default false не добавляет условий, а другое значение по-умолчанию нельзя выразить условием
+			if !value.Equal(ast.BooleanTerm(false)) {
+				return nil, fmt.Errorf("неподдерживаемое правило %v: значение по-умолчанию должно быть false", rule.Head.Ref())
+			}
+			continue
+		}
+
+		if !value.Equal(ast.BooleanTerm(true)) {
+			return nil, fmt.Errorf("неподдерживаемое правило %v: ожидалось булево правило", rule.Head.Ref())
+		}
+
+		bodies = append(bodies, rule.Body)
+	}
+
+	return t.queries(bodies)
+}
+
+// comparisons операторы сравнения Rego и SQL
+var comparisons = map[string]string{
+	ast.Equality.Name:      "=",
+	ast.Equal.Name:         "=",
+	ast.NotEqual.Name:      "<>",
+	ast.LessThan.Name:      "<",
+	ast.LessThanEq.Name:    "<=",
+	ast.GreaterThan.Name:   ">",
+	ast.GreaterThanEq.Name: ">=",
+}
+
+// flipped оператор сравнения с переставленными операндами
+var flipped = map[string]string{
+	"=":  "=",
+	"<>": "<>",
+	"<":  ">",
+	"<=": ">=",
+	">":  "<",
+	">=": "<=",
+}
+
+func (t translator) call(expr *ast.Expr) (filterNode, error) {
+	operator := expr.Operator().String()
+	operands := expr.Operands()
+
+	if op, ok := comparisons[operator]; ok && len(operands) == 2 {
+		// Неизвестное поле может быть с любой стороны сравнения
+		if node, ok := t.compare(operands[0], operands[1], op, nil); ok {
+			return node, nil
+		}
+		if node, ok := t.compare(operands[1], operands[0], flipped[op], nil); ok {
+			return node, nil
+		}
+		return nil, unsupported(expr)
+	}
+
+	// object.get(input, "поле", значение по-умолчанию, результат)
+	if operator == ast.ObjectGet.Name && len(operands) == 4 && operands[0].Equal(ast.NewTerm(ast.InputRootRef)) {
+		key, ok := operands[1].Value.(ast.String)
+		if !ok {
+			return nil, unsupported(expr)
+		}
+
+		field := ast.NewTerm(ast.InputRootRef.Append(ast.StringTerm(string(key))))
+		if node, ok := t.compare(field, operands[3], "=", operands[2]); ok {
+			return node, nil
+		}
+	}
+
+	return nil, unsupported(expr)
+}
+
+// compare сравнение неизвестного поля field со скалярным значением value.
+// def - значение по-умолчанию для отсутствующего поля (object.get), nil - без значения по-умолчанию.
+func (t translator) compare(field, value *ast.Term, op string, def *ast.Term) (filterNode, bool) {
+	ref, ok := field.Value.(ast.Ref)
+	if !ok || !ref.HasPrefix(ast.InputRootRef) || !ast.IsScalar(value.Value) {
+		return nil, false
+	}
+
+	name, err := refField(ref)
+	if err != nil || !t.fields[name] {
+		return nil, false
+	}
+
+	node := compareNode{
+		field: name,
+		op:    op,
+		value: scalarValue(value),
+	}
+
+	if def != nil {
+		if !ast.IsScalar(def.Value) {
+			return nil, false
+		}
+		node.hasDefault = true
+		node.def = scalarValue(def)
+	}
+
+	return node, true
+}
+
+func scalarValue(term *ast.Term) interface{} {
+	value, err := ast.JSON(term.Value)
+	if err != nil {
+		return nil
+	}
+
+	return value
+}
+
+func unsupported(expr *ast.Expr) error {
+	return fmt.Errorf("не удалось перевести остаточное условие %v в фильтр", expr)
+}
+
+// filterNode узел условия Filter
+type filterNode interface {
+	sql(args *[]interface{}) string
+	match(fields map[string]interface{}) bool
+}
+
+// orNode дизъюнкция. Пустая дизъюнкция не выполняется никогда.
+type orNode []filterNode
+
+func (n orNode) simplify() filterNode {
+	if len(n) == 1 {
+		return n[0]
+	}
+
+	for _, child := range n {
+		// Одна всегда выполняющаяся ветка делает выполняющейся всю дизъюнкцию
+		if and, ok := child.(andNode); ok && len(and) == 0 {
+			return and
+		}
+	}
+
+	return n
+}
+
+func (n orNode) sql(args *[]interface{}) string {
+	if len(n) == 0 {
+		return "1 = 0"
+	}
+
+	return joinSQL(n, " OR ", args)
+}
+
+func (n orNode) match(fields map[string]interface{}) bool {
+	for _, child := range n {
+		if child.match(fields) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// andNode конъюнкция. Пустая конъюнкция выполняется всегда.
+type andNode []filterNode
+
+func (n andNode) simplify() filterNode {
+	if len(n) == 1 {
+		return n[0]
+	}
+
+	for _, child := range n {
+		// Одна никогда не выполняющаяся часть делает невыполнимой всю конъюнкцию
+		if or, ok := child.(orNode); ok && len(or) == 0 {
+			return or
+		}
+	}
+
+	return n
+}
+
+func (n andNode) sql(args *[]interface{}) string {
+	if len(n) == 0 {
+		return "1 = 1"
+	}
+
+	return joinSQL(n, " AND ", args)
+}
+
+func (n andNode) match(fields map[string]interface{}) bool {
+	for _, child := range n {
+		if !child.match(fields) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func joinSQL(nodes []filterNode, sep string, args *[]interface{}) string {
+	parts := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		parts = append(parts, node.sql(args))
+	}
+
+	if len(parts) == 1 {
+		return parts[0]
+	}
+
+	return "(" + strings.Join(parts, sep) + ")"
+}
+
+// notNode отрицание. В Rego not выполняется, если выражение не определено (например поле не передано),
+// поэтому NULL в SQL приводится к FALSE до отрицания.
+type notNode struct {
+	node filterNode
+}
+
+func (n notNode) sql(args *[]interface{}) string {
+	return "NOT COALESCE(" + n.node.sql(args) + ", FALSE)"
+}
+
+func (n notNode) match(fields map[string]interface{}) bool {
+	return !n.node.match(fields)
+}
+
+// compareNode сравнение поля со значением
+type compareNode struct {
+	field string
+	op    string
+	value interface{}
+	// hasDefault для отсутствующего поля используется значение def (object.get)
+	hasDefault bool
+	def        interface{}
+}
+
+func (n compareNode) sql(args *[]interface{}) string {
+	column := quoteIdent(n.field)
+	if n.hasDefault {
+		column = "COALESCE(" + column + ", ?)"
+		*args = append(*args, sqlValue(n.def))
+	}
+
+	*args = append(*args, sqlValue(n.value))
+
+	return column + " " + n.op + " ?"
+}
+
+func (n compareNode) match(fields map[string]interface{}) bool {
+	actual, ok := fields[n.field]
+	if !ok || actual == nil {
+		if !n.hasDefault {
+			// Сравнение с отсутствующим полем в Rego не определено
+			return false
+		}
+		actual = n.def
+	}
+
+	// Значения приводятся к JSON-виду, в котором их сравнивает OPA: числа - json.Number
+	if err := util.RoundTrip(&actual); err != nil {
+		return false
+	}
+
+	cmp := util.Compare(actual, n.value)
+	switch n.op {
+	case "=":
+		return cmp == 0
+	case "<>":
+		return cmp != 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	default:
+		return false
+	}
+}
+
+// quoteIdent экранирует имя колонки. Точка во вложенном поле (a.b) остается частью имени.
+func quoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// sqlValue приводит значение к типу, который принимает database/sql: числа json.Number - к int64 или float64
+func sqlValue(value interface{}) interface{} {
+	n, ok := value.(json.Number)
+	if !ok {
+		return value
+	}
+
+	if i, err := n.Int64(); err == nil {
+		return i
+	}
+	if f, err := n.Float64(); err == nil {
+		return f
+	}
+
+	return n.String()
+}
@@ -0,0 +1,304 @@
+package policy
+
+import (
+	"context"
+	"database/sql"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/open-policy-agent/opa/ast"
+	_ "modernc.org/sqlite"
+)
+
+const filterModule = `package resources
+
+default allow = false
+
+allow {
+	input.role == "admin"
+}
+
+allow {
+	input.role == "reader"
+	input.owner == input.user
+}
+
+allow {
+	input.role == "reader"
+	input.level <= 1
+	object.get(input, "kind", "document") == "document"
+	not archived
+}
+
+allow {
+	input.role == "auditor"
+	startswith(input.owner, "a")
+}
+
+allow {
+	input.role == "drafts_reader"
+	not archived with input.status as "draft"
+}
+
+allow {
+	input.role == "media_reader"
+	media
+}
+
+archived {
+	input.status == "archived"
+}
+
+media {
+	input.kind == "image"
+} else {
+	input.kind == "video"
+}
+
+editable {
+	input.role == "editor"
+	input.owner == input.user
+}
+`
+
+// filterUnknowns поля ресурса, значения которых лежат в таблице
+var filterUnknowns = []string{"input.owner", "input.level", "input.kind", "input.status"}
+
+// openFilterDB таблица ресурсов в SQLite в памяти. NULL - поле не передано.
+func openFilterDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	// У каждого соединения своя база в памяти, поэтому соединение одно
+	db.SetMaxOpenConns(1)
+
+	for _, stmt := range []string{
+		`CREATE TABLE resources (id INTEGER PRIMARY KEY, owner TEXT, level INTEGER, kind TEXT, status TEXT)`,
+		`INSERT INTO resources (id, owner, level, kind, status) VALUES
+			(1, 'alice', 5, 'document', 'draft'),
+			(2, 'bob', 1, 'document', 'draft'),
+			(3, 'bob', 1, NULL, NULL),
+			(4, 'bob', 1, 'image', NULL),
+			(5, 'bob', 0, 'document', 'archived'),
+			(6, NULL, NULL, NULL, NULL),
+			(7, 'alice', NULL, NULL, 'archived'),
+			(8, 'bob', 2, NULL, NULL)`,
+	} {
+		if _, err = db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return db
+}
+
+// filterRow строка таблицы: идентификатор и поля, кроме NULL
+type filterRow struct {
+	id     int64
+	fields map[string]interface{}
+}
+
+func queryFilterRows(t *testing.T, db *sql.DB, where string, args ...interface{}) []filterRow {
+	t.Helper()
+
+	rows, err := db.Query("SELECT id, owner, level, kind, status FROM resources WHERE "+where+" ORDER BY id", args...)
+	if err != nil {
+		t.Fatalf("запрос с WHERE %s: %v", where, err)
+	}
+	defer rows.Close()
+
+	columns := []string{"owner", "level", "kind", "status"}
+
+	var result []filterRow
+	for rows.Next() {
+		var id int64
+		values := make([]interface{}, len(columns))
+		dest := []interface{}{&id}
+		for i := range values {
+			dest = append(dest, &values[i])
+		}
+		if err = rows.Scan(dest...); err != nil {
+			t.Fatal(err)
+		}
+
+		row := filterRow{id: id, fields: make(map[string]interface{})}
+		for i, column := range columns {
+			if values[i] != nil {
+				row.fields[column] = values[i]
+			}
+		}
+		result = append(result, row)
+	}
+	if err = rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	return result
+}
+
+// TestFilter проверяет, что строки, выбранные по WHERE из Filter.SQL, совпадают со строками,
+// для которых выполняется Filter.Match и для которых политика, вычисленная по каждой строке, разрешает доступ
+func TestFilter(t *testing.T) {
+	ctx := context.Background()
+	db := openFilterDB(t)
+	all := queryFilterRows(t, db, "1 = 1")
+
+	tests := []struct {
+		name  string
+		query string
+		role  string
+		where string
+		args  []interface{}
+		ids   []int64
+		err   string
+	}{
+		{
+			name:  "разрешено для всех строк",
+			query: "data.resources.allow",
+			role:  "admin",
+			where: "1 = 1",
+			ids:   []int64{1, 2, 3, 4, 5, 6, 7, 8},
+		},
+		{
+			name:  "условие на поля",
+			query: "data.resources.allow",
+			role:  "reader",
+			where: `("owner" = ? OR ("level" <= ? AND COALESCE("kind", ?) = ? AND NOT COALESCE("status" = ?, FALSE)))`,
+			args:  []interface{}{"alice", int64(1), "document", "document", "archived"},
+			ids:   []int64{1, 2, 3, 7},
+		},
+		{
+			name:  "запрещено для всех строк",
+			query: "data.resources.allow",
+			role:  "guest",
+			where: "1 = 0",
+		},
+		{
+			name:  "неподдерживаемая встроенная функция",
+			query: "data.resources.allow",
+			role:  "auditor",
+			err:   `не удалось перевести остаточное условие startswith(input.owner, "a") в фильтр`,
+		},
+		{
+			name:  "модификатор with",
+			query: "data.resources.allow",
+			role:  "drafts_reader",
+			err:   `не удалось перевести остаточное условие not data.partial.resources.archived with input.status as "draft" в фильтр`,
+		},
+		{
+			name:  "правило с else",
+			query: "data.resources.allow",
+			role:  "media_reader",
+			err:   "не удалось перевести остаточное условие data.resources.media в фильтр",
+		},
+		{
+			name:  "правило без default не определено",
+			query: "data.resources.editable",
+			role:  "reader",
+			where: "1 = 0",
+		},
+		{
+			name:  "правило без default определено при условии",
+			query: "data.resources.editable",
+			role:  "editor",
+			where: `"owner" = ?`,
+			args:  []interface{}{"alice"},
+			ids:   []int64{1, 7},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine := New(tt.query, WithModule("resources.rego", filterModule))
+			input := map[string]interface{}{"role": tt.role, "user": "alice"}
+
+			filter, err := engine.Filter(ctx, input, filterUnknowns...)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("ошибка %v, ожидалась %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Filter: %v", err)
+			}
+
+			where, args := filter.SQL()
+			if where != tt.where || !reflect.DeepEqual(args, tt.args) {
+				t.Errorf("WHERE %s %v, ожидалось %s %v", where, args, tt.where, tt.args)
+			}
+			if filter.Always() != (tt.where == "1 = 1") || filter.Never() != (tt.where == "1 = 0") {
+				t.Errorf("Always %v, Never %v", filter.Always(), filter.Never())
+			}
+
+			var bySQL, byMatch, byEval []int64
+			for _, row := range queryFilterRows(t, db, where, args...) {
+				bySQL = append(bySQL, row.id)
+			}
+
+			for _, row := range all {
+				if filter.Match(row.fields) {
+					byMatch = append(byMatch, row.id)
+				}
+
+				rowInput := map[string]interface{}{"role": tt.role, "user": "alice"}
+				for key, value := range row.fields {
+					rowInput[key] = value
+				}
+
+				decision, err := engine.Eval(ctx, rowInput)
+				if err != nil {
+					t.Fatalf("Eval(%v): %v", rowInput, err)
+				}
+				if decision.Defined && decision.Value == true {
+					byEval = append(byEval, row.id)
+				}
+			}
+
+			if !reflect.DeepEqual(bySQL, tt.ids) {
+				t.Errorf("SQL выбрал %v, ожидалось %v", bySQL, tt.ids)
+			}
+			if !reflect.DeepEqual(byMatch, bySQL) || !reflect.DeepEqual(byEval, bySQL) {
+				t.Errorf("результаты расходятся: SQL %v, Filter.Match %v, проверка каждой строки %v", bySQL, byMatch, byEval)
+			}
+		})
+	}
+}
+
+// TestFilterElseRule проверяет правило с else среди вспомогательных правил частичного вычисления.
+// OPA не раскрывает такие правила при частичном вычислении, но translator не должен молча отбрасывать else.
+func TestFilterElseRule(t *testing.T) {
+	module := ast.MustParseModule(`package partial.resources
+
+media {
+	input.kind == "image"
+} else {
+	input.kind == "video"
+}
+`)
+
+	tr := translator{
+		fields: map[string]bool{"kind": true},
+		rules:  map[string][]*ast.Rule{"data.partial.resources.media": module.Rules},
+	}
+
+	_, err := tr.queries([]ast.Body{ast.MustParseBody("data.partial.resources.media")})
+	if err == nil || !strings.Contains(err.Error(), "правила с else не поддерживаются") {
+		t.Errorf("ошибка %v, ожидалась ошибка о правиле с else", err)
+	}
+}
+
+func TestFilterUnknowns(t *testing.T) {
+	engine := New("data.resources.allow", WithModule("resources.rego", filterModule))
+
+	for _, unknown := range []string{"owner", "data.resources.owner", "input", "input[_]"} {
+		if _, err := engine.Filter(context.Background(), nil, unknown); err == nil {
+			t.Errorf("неизвестное поле %q принято без ошибки", unknown)
+		}
+	}
+}
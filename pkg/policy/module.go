@@ -0,0 +1,117 @@
+package policy
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/open-policy-agent/opa/loader"
+)
+
+// Module rego-модуль, который загружается в движок в виде строки
+type Module struct {
+	// Name имя модуля, которое используется для отладки и в сообщениях об ошибках.
+	// Если политика загружается из файла, то хорошей практикой будет назвать модуль по имени файла.
+	Name string
+	// Source код политики на языке rego
+	Source string
+}
+
+// RenderTemplate генерирует rego-модуль из шаблона text/template.
+// Имя модуля получается из имени файла шаблона с заменой расширения на .rego.
+// В шаблоне доступны функции экранирования TemplateFuncs.
+func RenderTemplate(templatePath string, data interface{}) (Module, error) {
+	// Загружаем шаблон из файла
+	tmpl, err := template.New(filepath.Base(templatePath)).Funcs(TemplateFuncs()).ParseFiles(templatePath)
+	if err != nil {
+		return Module{}, fmt.Errorf("ошибка загрузки шаблона: %w", err)
+	}
+
+	// Применяем шаблон к данным
+	var output bytes.Buffer
+	err = tmpl.Execute(&output, data)
+	if err != nil {
+		return Module{}, fmt.Errorf("ошибка выполнения шаблона: %w", err)
+	}
+
+	return Module{
+		Name:   moduleName(templatePath),
+		Source: output.String(),
+	}, nil
+}
+
+// RenderTemplates генерирует rego-модули из нескольких шаблонов с одними и теми же данными
+func RenderTemplates(data interface{}, templatePaths ...string) ([]Module, error) {
+	modules := make([]Module, 0, len(templatePaths))
+	for _, path := range templatePaths {
+		module, err := RenderTemplate(path, data)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка генерации шаблона %s: %w", filepath.Base(path), err)
+		}
+
+		modules = append(modules, module)
+	}
+
+	return modules, nil
+}
+
+func moduleName(templatePath string) string {
+	base := filepath.Base(templatePath)
+	return strings.TrimSuffix(base, filepath.Ext(base)) + ".rego"
+}
+
+// LoadModules загружает политики из rego-файлов или директорий с ними так же, как WithFiles:
+// файлы *_test.rego и прочие файлы пропускаются. Модули называются путями к файлам и отсортированы по ним.
+func LoadModules(paths ...string) ([]Module, error) {
+	res, err := loader.NewFileLoader().Filtered(paths, policyFilesOnly)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка загрузки политик: %w", err)
+	}
+
+	modules := make([]Module, 0, len(res.Modules))
+	for _, file := range res.Modules {
+		modules = append(modules, Module{
+			Name:   file.Name,
+			Source: string(file.Raw),
+		})
+	}
+
+	sort.Slice(modules, func(i, j int) bool {
+		return modules[i].Name < modules[j].Name
+	})
+
+	return modules, nil
+}
+
+// ModuleRules возвращает полные пути правил модулей без функций, например data.final_check.result,
+// без повторов и в отсортированном порядке. По ним можно проверить запрос до создания движка.
+func ModuleRules(modules ...Module) ([]string, error) {
+	seen := make(map[string]bool)
+	for _, module := range modules {
+		parsed, err := ast.ParseModule(module.Name, module.Source)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка разбора политики %s: %w", module.Name, err)
+		}
+
+		for _, rule := range parsed.Rules {
+			if len(rule.Head.Args) > 0 {
+				continue
+			}
+
+			// У частичных правил (violations[v]) запрос - к документу целиком, то есть к постоянной части пути
+			seen[rule.Ref().GroundPrefix().String()] = true
+		}
+	}
+
+	rules := make([]string, 0, len(seen))
+	for rule := range seen {
+		rules = append(rules, rule)
+	}
+	sort.Strings(rules)
+
+	return rules, nil
+}
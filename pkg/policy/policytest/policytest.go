@@ -0,0 +1,117 @@
+// Package policytest запускает тесты политик (*_test.rego) из go test.
+//
+// Каждое правило test_* становится подтестом вида <пакет>/<правило>, а покрытие политик
+// тестами выводится в лог теста:
+//
+//	func TestPolicies(t *testing.T) {
+//		policytest.Run(t, []string{"."})
+//	}
+//
+// RunCases так же выполняет файлы кейсов (пакет cases): каждый кейс становится подтестом,
+// а отличия результата от ожидаемого - ошибками подтеста:
+//
+//	func TestCases(t *testing.T) {
+//		policytest.RunCases[AccessRequest](t, newEngine(), "cases.yaml")
+//	}
+package policytest
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/olezhek28/access_policy/pkg/policy"
+	"github.com/olezhek28/access_policy/pkg/policy/cases"
+)
+
+type config struct {
+	minCoverage float64
+}
+
+// Option настраивает запуск тестов политик
+type Option func(c *config)
+
+// WithMinCoverage роняет тест, если покрытие политик тестами (в процентах) ниже min
+func WithMinCoverage(min float64) Option {
+	return func(c *config) {
+		c.minCoverage = min
+	}
+}
+
+// Run находит *_test.rego по путям paths и выполняет их как подтесты t
+func Run(t *testing.T, paths []string, opts ...Option) {
+	t.Helper()
+
+	cfg := config{}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	report, err := policy.RunTests(context.Background(), paths...)
+	if err != nil {
+		t.Fatalf("ошибка при запуске тестов политик: %v", err)
+	}
+
+	if len(report.Results) == 0 {
+		t.Skipf("тесты политик не найдены в %s", strings.Join(paths, ", "))
+	}
+
+	for _, res := range report.Results {
+		t.Run(res.Package+"/"+res.Name, func(t *testing.T) {
+			if res.Output != "" {
+				t.Logf("вывод print():\n%s", res.Output)
+			}
+
+			switch {
+			case res.Skip:
+				t.Skipf("%s: тест пропущен", res.Location)
+			case res.Err != nil:
+				t.Fatalf("%s: ошибка при выполнении теста: %v\n%s", res.Location, res.Err, res.Trace)
+			case res.Fail:
+				t.Fatalf("%s: тест не пройден\nне выполнилось: %s\n%s", res.Location, res.FailedAt, res.Trace)
+			}
+		})
+	}
+
+	files := make([]string, 0, len(report.Coverage.Files))
+	for file := range report.Coverage.Files {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+
+	for _, file := range files {
+		t.Logf("покрытие %s: %.1f%%", file, report.Coverage.Files[file].Coverage)
+	}
+	t.Logf("покрытие политик тестами: %.1f%%", report.Coverage.Coverage)
+
+	if cfg.minCoverage > 0 && report.Coverage.Coverage < cfg.minCoverage {
+		t.Errorf("покрытие политик тестами %.1f%% ниже требуемого %.1f%%", report.Coverage.Coverage, cfg.minCoverage)
+	}
+}
+
+// RunCases загружает кейсы из файлов paths и выполняет их движком engine как подтесты t
+func RunCases[T any](t *testing.T, engine *policy.Engine, paths ...string) {
+	t.Helper()
+
+	loaded, err := cases.Load[T](paths...)
+	if err != nil {
+		t.Fatalf("ошибка при загрузке кейсов: %v", err)
+	}
+
+	if len(loaded) == 0 {
+		t.Skipf("кейсы не найдены в %s", strings.Join(paths, ", "))
+	}
+
+	report := cases.Run(context.Background(), engine, loaded)
+	for _, res := range report.Results {
+		t.Run(res.Name, func(t *testing.T) {
+			if res.Err != nil {
+				t.Fatalf("ошибка при выполнении кейса: %v", res.Err)
+			}
+			for _, d := range res.Diffs {
+				t.Error(d)
+			}
+		})
+	}
+}
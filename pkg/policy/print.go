@@ -0,0 +1,41 @@
+package policy
+
+import (
+	"log/slog"
+
+	"github.com/open-policy-agent/opa/topdown/print"
+)
+
+// WithPrintLogger включает вывод print() из политик в logger для всех запросов движка.
+// Для отдельного запроса вывод включается через EvalPrint.
+func WithPrintLogger(logger *slog.Logger) Option {
+	return func(e *Engine) {
+		e.printLogger = logger
+	}
+}
+
+// printHook направляет каждую строку print() в slog вместе с идентификатором решения,
+// именем модуля и номером строки, в которой вызван print
+type printHook struct {
+	logger     *slog.Logger
+	query      string
+	decisionID string
+}
+
+func (h printHook) Print(pctx print.Context, msg string) error {
+	attrs := []slog.Attr{
+		slog.String("decision_id", h.decisionID),
+		slog.String("query", h.query),
+	}
+
+	if pctx.Location != nil {
+		attrs = append(attrs,
+			slog.String("module", pctx.Location.File),
+			slog.Int("line", pctx.Location.Row),
+		)
+	}
+
+	h.logger.LogAttrs(pctx.Context, slog.LevelInfo, msg, attrs...)
+
+	return nil
+}
@@ -0,0 +1,142 @@
+package policy
+
+import (
+	"context"
+	"log/slog"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// recordHandler запоминает записи slog вместе с атрибутами
+type recordHandler struct {
+	mu      sync.Mutex
+	records []printRecord
+}
+
+// printRecord строка print() в том виде, в каком она попала в slog
+type printRecord struct {
+	msg   string
+	attrs map[string]interface{}
+}
+
+func (h *recordHandler) Enabled(context.Context, slog.Level) bool {
+	return true
+}
+
+func (h *recordHandler) Handle(_ context.Context, r slog.Record) error {
+	rec := printRecord{msg: r.Message, attrs: make(map[string]interface{})}
+	r.Attrs(func(a slog.Attr) bool {
+		rec.attrs[a.Key] = a.Value.Any()
+		return true
+	})
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.records = append(h.records, rec)
+
+	return nil
+}
+
+func (h *recordHandler) WithAttrs([]slog.Attr) slog.Handler {
+	return h
+}
+
+func (h *recordHandler) WithGroup(string) slog.Handler {
+	return h
+}
+
+func (h *recordHandler) take() []printRecord {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	records := h.records
+	h.records = nil
+
+	return records
+}
+
+const printModule = `package authz
+
+allow {
+	print("role", input.role)
+	input.role == "admin"
+}
+`
+
+func TestPrint(t *testing.T) {
+	ctx := context.Background()
+	input := map[string]interface{}{"role": "admin"}
+
+	wantRecord := func(decisionID string) []printRecord {
+		return []printRecord{{
+			msg: "role admin",
+			attrs: map[string]interface{}{
+				"decision_id": decisionID,
+				"query":       "data.authz.allow",
+				"module":      "authz.rego",
+				"line":        int64(4),
+			},
+		}}
+	}
+
+	// Без WithPrintLogger и EvalPrint print() ничего не выводит, в том числе в logger по-умолчанию
+	defaultLog := &recordHandler{}
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(slog.New(defaultLog))
+
+	engine := New("data.authz.allow", WithModule("authz.rego", printModule))
+	if _, err := engine.Eval(ctx, input); err != nil {
+		t.Fatalf("Eval: %v", err)
+	}
+	if got := defaultLog.take(); len(got) != 0 {
+		t.Errorf("вывод без WithPrintLogger и EvalPrint: %+v", got)
+	}
+
+	// EvalPrint включает вывод для одного запроса с переданным идентификатором решения
+	requestLog := &recordHandler{}
+	decision, err := engine.Eval(ctx, input, EvalPrint(slog.New(requestLog)), EvalDecisionID("decision-1"))
+	if err != nil {
+		t.Fatalf("Eval: %v", err)
+	}
+	if decision.ID != "decision-1" {
+		t.Errorf("идентификатор решения %q", decision.ID)
+	}
+	if got := requestLog.take(); !reflect.DeepEqual(got, wantRecord("decision-1")) {
+		t.Errorf("вывод EvalPrint %+v, ожидался %+v", got, wantRecord("decision-1"))
+	}
+
+	// Следующий запрос без EvalPrint снова ничего не выводит
+	if _, err = engine.Eval(ctx, input); err != nil {
+		t.Fatalf("Eval: %v", err)
+	}
+	if got := requestLog.take(); len(got) != 0 {
+		t.Errorf("вывод без EvalPrint: %+v", got)
+	}
+
+	// WithPrintLogger включает вывод для всех запросов движка, а идентификатор решения генерируется
+	engineLog := &recordHandler{}
+	engine = New("data.authz.allow", WithModule("authz.rego", printModule), WithPrintLogger(slog.New(engineLog)))
+	if decision, err = engine.Eval(ctx, input); err != nil {
+		t.Fatalf("Eval: %v", err)
+	}
+	if _, err = uuid.Parse(decision.ID); err != nil {
+		t.Errorf("сгенерированный идентификатор решения %q не UUID", decision.ID)
+	}
+	if got := engineLog.take(); !reflect.DeepEqual(got, wantRecord(decision.ID)) {
+		t.Errorf("вывод WithPrintLogger %+v, ожидался %+v", got, wantRecord(decision.ID))
+	}
+
+	// EvalPrint заменяет logger движка для одного запроса
+	if _, err = engine.Eval(ctx, input, EvalPrint(slog.New(requestLog)), EvalDecisionID("decision-2")); err != nil {
+		t.Fatalf("Eval: %v", err)
+	}
+	if got := engineLog.take(); len(got) != 0 {
+		t.Errorf("вывод в logger движка при EvalPrint: %+v", got)
+	}
+	if got := requestLog.take(); !reflect.DeepEqual(got, wantRecord("decision-2")) {
+		t.Errorf("вывод EvalPrint %+v, ожидался %+v", got, wantRecord("decision-2"))
+	}
+}
@@ -0,0 +1,152 @@
+package policy
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/open-policy-agent/opa/util"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// InputError ошибка проверки входных данных по JSON Schema (WithInputSchema).
+// В отличие от отказа в доступе, политика для таких данных не вычисляется:
+// ошибку нужно исправить на стороне клиента.
+type InputError struct {
+	// Errors нарушения схемы по полям
+	Errors []FieldError
+}
+
+// FieldError нарушение схемы входных данных
+type FieldError struct {
+	// Field путь к полю через точку, например user_permissions.0. Для всего документа - пустая строка.
+	Field string `json:"field"`
+	// Message описание нарушения
+	Message string `json:"message"`
+}
+
+func (e *InputError) Error() string {
+	msgs := make([]string, 0, len(e.Errors))
+	for _, fe := range e.Errors {
+		if fe.Field == "" {
+			msgs = append(msgs, fe.Message)
+			continue
+		}
+
+		msgs = append(msgs, fe.Field+": "+fe.Message)
+	}
+
+	return "некорректные входные данные: " + strings.Join(msgs, "; ")
+}
+
+// InputSchema загруженная JSON Schema входных данных
+type InputSchema struct {
+	path string
+	raw  []byte
+	doc  interface{}
+	// schema скомпилированная схема для проверки входных данных
+	schema *gojsonschema.Schema
+}
+
+// LoadInputSchema загружает JSON Schema входных данных из JSON- или YAML-файла
+func LoadInputSchema(path string) (*InputSchema, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка загрузки схемы входных данных: %w", err)
+	}
+
+	var doc interface{}
+	if err = util.Unmarshal(raw, &doc); err != nil {
+		return nil, fmt.Errorf("ошибка загрузки схемы входных данных из %s: %w", path, err)
+	}
+
+	schema, err := gojsonschema.NewSchema(gojsonschema.NewGoLoader(doc))
+	if err != nil {
+		return nil, fmt.Errorf("некорректная схема входных данных %s: %w", path, err)
+	}
+
+	return &InputSchema{
+		path:   path,
+		raw:    raw,
+		doc:    doc,
+		schema: schema,
+	}, nil
+}
+
+// SchemaSet возвращает набор схем для проверки типов компилятором OPA: схема входных данных лежит по ключу schema
+func (s *InputSchema) SchemaSet() *ast.SchemaSet {
+	schemas := ast.NewSchemaSet()
+	schemas.Put(ast.SchemaRootRef, s.doc)
+
+	return schemas
+}
+
+// WithInputSchema задает JSON Schema входных данных (JSON- или YAML-файл).
+// Схема используется дважды: при компиляции ее получает проверка типов OPA, поэтому обращение политики
+// к несуществующему полю input или сравнение со значением другого типа - ошибка компиляции,
+// а перед каждым вычислением по ней проверяются входные данные. Для данных, не прошедших проверку,
+// Eval возвращает *InputError, а не решение.
+// Файл читается заново при каждой компиляции, поэтому Reload подхватывает изменения схемы.
+func WithInputSchema(path string) Option {
+	return func(e *Engine) {
+		e.schemaPath = path
+	}
+}
+
+// WithLoadedInputSchema задает уже загруженную схему входных данных (LoadInputSchema).
+// В отличие от WithInputSchema, файл схемы не читается при компиляции.
+func WithLoadedInputSchema(schema *InputSchema) Option {
+	return func(e *Engine) {
+		e.schema = schema
+	}
+}
+
+// inputSchema возвращает схему входных данных движка: из файла WithInputSchema или загруженную заранее.
+// nil, если схема не задана.
+func (e *Engine) inputSchema() (*InputSchema, error) {
+	if e.schemaPath != "" {
+		return LoadInputSchema(e.schemaPath)
+	}
+
+	return e.schema, nil
+}
+
+// validateInput проверяет входные данные по схеме
+func validateInput(schema *gojsonschema.Schema, input interface{}) error {
+	res, err := schema.Validate(gojsonschema.NewGoLoader(input))
+	if err != nil {
+		return fmt.Errorf("ошибка проверки входных данных: %w", err)
+	}
+
+	if res.Valid() {
+		return nil
+	}
+
+	inputErr := &InputError{
+		Errors: make([]FieldError, 0, len(res.Errors())),
+	}
+	for _, re := range res.Errors() {
+		field := re.Field()
+		if field == gojsonschema.STRING_CONTEXT_ROOT {
+			field = ""
+		}
+
+		// Для отсутствующего обязательного поля и лишнего поля ошибка относится к объекту,
+		// а само поле передается в деталях
+		if property, ok := re.Details()["property"].(string); ok {
+			if field == "" {
+				field = property
+			} else {
+				field += "." + property
+			}
+		}
+
+		inputErr.Errors = append(inputErr.Errors, FieldError{
+			Field:   field,
+			Message: re.Description(),
+		})
+	}
+
+	return inputErr
+}
@@ -0,0 +1,154 @@
+package policy
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testInputSchema = `{
+    "type": "object",
+    "properties": {
+        "action": {"type": "string"},
+        "user_permissions": {"type": "array", "items": {"type": "string"}}
+    },
+    "required": ["action", "user_permissions"],
+    "additionalProperties": false
+}`
+
+func loadTestSchema(t *testing.T) *InputSchema {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "input.json")
+	if err := os.WriteFile(path, []byte(testInputSchema), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	schema, err := LoadInputSchema(path)
+	if err != nil {
+		t.Fatalf("LoadInputSchema: %v", err)
+	}
+
+	return schema
+}
+
+func TestValidateInput(t *testing.T) {
+	schema := loadTestSchema(t)
+
+	tests := []struct {
+		name  string
+		input interface{}
+		want  []FieldError
+	}{
+		{
+			name:  "корректные данные",
+			input: map[string]interface{}{"action": "read", "user_permissions": []interface{}{"read"}},
+		},
+		{
+			name:  "неверный тип",
+			input: map[string]interface{}{"action": "read", "user_permissions": []interface{}{"read", 42}},
+			want:  []FieldError{{Field: "user_permissions.1", Message: "Invalid type. Expected: string, given: integer"}},
+		},
+		{
+			name:  "нет обязательного поля",
+			input: map[string]interface{}{"user_permissions": []interface{}{}},
+			want:  []FieldError{{Field: "action", Message: "action is required"}},
+		},
+		{
+			name:  "лишнее поле",
+			input: map[string]interface{}{"action": "read", "user_permissions": []interface{}{}, "role": "admin"},
+			want:  []FieldError{{Field: "role", Message: "Additional property role is not allowed"}},
+		},
+		{
+			name:  "не объект",
+			input: "read",
+			want:  []FieldError{{Field: "", Message: "Invalid type. Expected: object, given: string"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateInput(schema.schema, tt.input)
+			if tt.want == nil {
+				if err != nil {
+					t.Fatalf("ошибка для корректных данных: %v", err)
+				}
+				return
+			}
+
+			var inputErr *InputError
+			if !errors.As(err, &inputErr) {
+				t.Fatalf("ошибка %v, ожидалась *InputError", err)
+			}
+			if !reflect.DeepEqual(inputErr.Errors, tt.want) {
+				t.Errorf("нарушения %+v, ожидались %+v", inputErr.Errors, tt.want)
+			}
+		})
+	}
+}
+
+// TestInputSchemaTypeCheck проверяет, что схема входных данных участвует в проверке типов при компиляции политики
+func TestInputSchemaTypeCheck(t *testing.T) {
+	ctx := context.Background()
+	schema := loadTestSchema(t)
+
+	engine := New("data.authz.allow",
+		WithModule("authz.rego", `package authz
+
+allow {
+	input.action == "read"
+	input.user_permissions[_] == "read"
+}
+`),
+		WithLoadedInputSchema(schema),
+	)
+	if err := engine.Prepare(ctx); err != nil {
+		t.Fatalf("политика с полями из схемы не скомпилировалась: %v", err)
+	}
+
+	// Входные данные проверяются по схеме до вычисления политики
+	var inputErr *InputError
+	if _, err := engine.Eval(ctx, map[string]interface{}{"action": "read"}); !errors.As(err, &inputErr) {
+		t.Errorf("ошибка %v, ожидалась *InputError", err)
+	}
+
+	tests := []struct {
+		name   string
+		module string
+		want   string
+	}{
+		{
+			name: "поле не объявлено в схеме",
+			module: `package authz
+
+allow {
+	input.role == "admin"
+}
+`,
+			want: "undefined ref: input.role",
+		},
+		{
+			name: "сравнение со значением другого типа",
+			module: `package authz
+
+allow {
+	input.action == 42
+}
+`,
+			want: "match error",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := New("data.authz.allow", WithModule("authz.rego", tt.module), WithLoadedInputSchema(schema)).Prepare(ctx)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("ошибка компиляции %v, ожидалась %q", err, tt.want)
+			}
+		})
+	}
+}
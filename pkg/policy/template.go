@@ -0,0 +1,148 @@
+package policy
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"text/template"
+
+	"github.com/open-policy-agent/opa/ast"
+)
+
+// templateExt расширение файлов шаблонов политик
+const templateExt = ".tmpl"
+
+// TemplateRegistry набор шаблонов политик, найденных в директории.
+// Шаблоны разбираются один раз при загрузке, а рендерятся при каждом вызове Render.
+type TemplateRegistry struct {
+	templates []namedTemplate
+}
+
+// TemplateOption настраивает загрузку шаблонов
+type TemplateOption func(o *templateOptions)
+
+type templateOptions struct {
+	strict bool
+}
+
+// StrictTemplates включает строгий режим: шаблон, который выводит значение не через
+// regoString, regoSet или regoNumber, отклоняется при загрузке
+func StrictTemplates() TemplateOption {
+	return func(o *templateOptions) {
+		o.strict = true
+	}
+}
+
+type namedTemplate struct {
+	// module имя rego-модуля, который получается из шаблона
+	module string
+	tmpl   *template.Template
+}
+
+// LoadTemplates находит все файлы *.tmpl в директории dir и ее поддиректориях
+func LoadTemplates(dir string, opts ...TemplateOption) (*TemplateRegistry, error) {
+	return LoadTemplatesFS(os.DirFS(dir), opts...)
+}
+
+// LoadTemplatesFS находит все файлы *.tmpl в файловой системе fsys, например во встроенной через embed.
+// Имя модуля получается из пути шаблона с заменой расширения на .rego: resource_check_policy.tmpl -> resource_check_policy.rego.
+// В шаблонах доступны функции экранирования TemplateFuncs.
+func LoadTemplatesFS(fsys fs.FS, opts ...TemplateOption) (*TemplateRegistry, error) {
+	var o templateOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	r := &TemplateRegistry{}
+
+	// WalkDir обходит файлы в лексическом порядке, поэтому порядок модулей всегда одинаковый
+	err := fs.WalkDir(fsys, ".", func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || path.Ext(name) != templateExt {
+			return nil
+		}
+
+		raw, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return fmt.Errorf("ошибка загрузки шаблона %s: %w", name, err)
+		}
+
+		// missingkey=error не дает отрендерить пустое значение вместо отсутствующего ключа
+		tmpl, err := template.New(name).Option("missingkey=error").Funcs(TemplateFuncs()).Parse(string(raw))
+		if err != nil {
+			return fmt.Errorf("ошибка загрузки шаблона %s: %w", name, err)
+		}
+
+		if o.strict {
+			if err = checkStrict(tmpl); err != nil {
+				return fmt.Errorf("ошибка загрузки шаблона %s: %w", name, err)
+			}
+		}
+
+		r.templates = append(r.templates, namedTemplate{
+			module: strings.TrimSuffix(name, templateExt) + ".rego",
+			tmpl:   tmpl,
+		})
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(r.templates) == 0 {
+		return nil, fmt.Errorf("шаблоны политик *%s не найдены", templateExt)
+	}
+
+	return r, nil
+}
+
+// Names возвращает имена модулей, которые генерирует реестр
+func (r *TemplateRegistry) Names() []string {
+	names := make([]string, 0, len(r.templates))
+	for _, t := range r.templates {
+		names = append(names, t.module)
+	}
+
+	return names
+}
+
+// Render генерирует rego-модули из всех шаблонов с данными data и проверяет,
+// что они разбираются и компилируются вместе. Модули возвращаются только если компиляция прошла успешно.
+func (r *TemplateRegistry) Render(data interface{}) ([]Module, error) {
+	modules := make([]Module, 0, len(r.templates))
+	parsed := make(map[string]*ast.Module, len(r.templates))
+
+	for _, t := range r.templates {
+		var output bytes.Buffer
+		if err := t.tmpl.Execute(&output, data); err != nil {
+			return nil, fmt.Errorf("ошибка генерации шаблона %s: %w", t.tmpl.Name(), err)
+		}
+
+		module := Module{
+			Name:   t.module,
+			Source: output.String(),
+		}
+
+		m, err := ast.ParseModule(module.Name, module.Source)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка разбора политики из шаблона %s: %w", t.tmpl.Name(), err)
+		}
+
+		modules = append(modules, module)
+		parsed[module.Name] = m
+	}
+
+	// Модули ссылаются друг на друга через import, поэтому компилируются вместе
+	compiler := ast.NewCompiler()
+	if compiler.Compile(parsed); compiler.Failed() {
+		return nil, fmt.Errorf("ошибка при компиляции политики: %w", compiler.Errors)
+	}
+
+	return modules, nil
+}
@@ -0,0 +1,216 @@
+package policy
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/open-policy-agent/opa/ast"
+)
+
+func TestRegoString(t *testing.T) {
+	tests := []string{
+		"some_slug",
+		"",
+		`"`,
+		`\`,
+		`"} {"`,
+		"line\nbreak\ttab",
+		"# comment",
+		"\x00",
+		"ё😀",
+		"<script>&</script>",
+	}
+
+	for _, s := range tests {
+		got, err := regoString(s)
+		if err != nil {
+			t.Errorf("regoString(%q): %v", s, err)
+			continue
+		}
+
+		// Литерал разбирается как одна строка rego с исходным значением
+		term, err := ast.ParseTerm(got)
+		if err != nil {
+			t.Errorf("regoString(%q) = %s: %v", s, got, err)
+			continue
+		}
+		if value, ok := term.Value.(ast.String); !ok || string(value) != s {
+			t.Errorf("regoString(%q) = %s, разобрано как %v", s, got, term)
+		}
+	}
+
+	if _, err := regoString("bad\xf9utf8"); err == nil {
+		t.Error("regoString: ожидалась ошибка для некорректного UTF-8")
+	}
+}
+
+func TestRegoNumber(t *testing.T) {
+	tests := []struct {
+		value   interface{}
+		want    string
+		wantErr bool
+	}{
+		{value: 42, want: "42"},
+		{value: int8(-5), want: "-5"},
+		{value: uint64(math.MaxUint64), want: "18446744073709551615"},
+		{value: 1.5, want: "1.5"},
+		{value: float32(0.25), want: "0.25"},
+		{value: 1e21, want: "1e+21"},
+		{value: json.Number("3.14"), want: "3.14"},
+		{value: "10", want: "10"},
+		{value: "-0.5e-3", want: "-0.5e-3"},
+		{value: "1; allow = true", wantErr: true},
+		{value: "01", wantErr: true},
+		{value: "", wantErr: true},
+		{value: json.Number("NaN"), wantErr: true},
+		{value: math.NaN(), wantErr: true},
+		{value: math.Inf(1), wantErr: true},
+		{value: true, wantErr: true},
+		{value: []int{1}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := regoNumber(tt.value)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("regoNumber(%#v) = %s, ожидалась ошибка", tt.value, got)
+			}
+			continue
+		}
+
+		if err != nil || got != tt.want {
+			t.Errorf("regoNumber(%#v) = %s, %v, ожидалось %s", tt.value, got, err, tt.want)
+			continue
+		}
+
+		if _, ok := ast.MustParseTerm(got).Value.(ast.Number); !ok {
+			t.Errorf("regoNumber(%#v) = %s не является числом rego", tt.value, got)
+		}
+	}
+}
+
+func TestRegoSet(t *testing.T) {
+	tests := []struct {
+		values  interface{}
+		want    string
+		wantErr bool
+	}{
+		{values: []string{"read", "write"}, want: `{"read", "write"}`},
+		{values: []string{}, want: "set()"},
+		{values: []string(nil), want: "set()"},
+		{values: [2]int{1, 2}, want: "{1, 2}"},
+		{values: []interface{}{"a", 1, true}, want: `{"a", 1, true}`},
+		{values: []string{`"}, input.x, {"`}, want: `{"\"}, input.x, {\""}`},
+		{values: "read", wantErr: true},
+		{values: []interface{}{map[string]string{}}, wantErr: true},
+		{values: []string{"\xff"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := regoSet(tt.values)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("regoSet(%#v) = %s, ожидалась ошибка", tt.values, got)
+			}
+			continue
+		}
+
+		if err != nil || got != tt.want {
+			t.Errorf("regoSet(%#v) = %s, %v, ожидалось %s", tt.values, got, err, tt.want)
+			continue
+		}
+
+		if _, ok := ast.MustParseTerm(got).Value.(ast.Set); !ok {
+			t.Errorf("regoSet(%#v) = %s не является множеством rego", tt.values, got)
+		}
+	}
+}
+
+func TestStrictTemplates(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		wantErr  string
+	}{
+		{
+			name:     "экранированные значения",
+			template: `package p` + "\n" + `slug := {{ regoString .Slug }}` + "\n" + `perms := {{ .Perms | regoSet }}` + "\n" + `max := {{ regoNumber .Max }}`,
+		},
+		{
+			name:     "присваивание переменной и ветки",
+			template: `package p` + "\n" + `{{ $slug := .Slug }}{{ if .Perms }}perms := {{ regoSet .Perms }}{{ else }}perms := set(){{ end }}` + "\n" + `slug := {{ regoString $slug }}`,
+		},
+		{
+			name:     "значение без экранирования",
+			template: `package p` + "\n" + `slug := "{{ .Slug }}"`,
+			wantErr:  "выводится без экранирования",
+		},
+		{
+			name:     "экранирование не последней командой конвейера",
+			template: `package p` + "\n" + `slug := {{ regoString .Slug | printf "%s" }}`,
+			wantErr:  "выводится без экранирования",
+		},
+		{
+			name:     "значение без экранирования внутри range",
+			template: `package p` + "\n" + `{{ range .Perms }}perm := "{{ . }}"{{ end }}`,
+			wantErr:  "выводится без экранирования",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys := fstest.MapFS{
+				"p.tmpl": {Data: []byte(tt.template)},
+			}
+
+			// Без строгого режима загружается любой корректный шаблон
+			if _, err := LoadTemplatesFS(fsys); err != nil {
+				t.Fatalf("LoadTemplatesFS: %v", err)
+			}
+
+			_, err := LoadTemplatesFS(fsys, StrictTemplates())
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("LoadTemplatesFS в строгом режиме: %v", err)
+				}
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("ошибка %v, ожидалась %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTemplateRender(t *testing.T) {
+	fsys := fstest.MapFS{
+		"resource.tmpl": {Data: []byte(`package resource` + "\n" + `slug := {{ regoString .Slug }}`)},
+	}
+
+	templates, err := LoadTemplatesFS(fsys, StrictTemplates())
+	if err != nil {
+		t.Fatalf("LoadTemplatesFS: %v", err)
+	}
+
+	modules, err := templates.Render(map[string]interface{}{"Slug": "\"}\nallow = true\n#"})
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	if len(modules) != 1 || modules[0].Name != "resource.rego" {
+		t.Fatalf("модули %+v, ожидался resource.rego", modules)
+	}
+
+	m := ast.MustParseModule(modules[0].Source)
+	if len(m.Rules) != 1 {
+		t.Errorf("значение добавило правила в политику:\n%s", modules[0].Source)
+	}
+
+	// Отсутствующий ключ - ошибка генерации, а не пустое значение
+	if _, err = templates.Render(map[string]interface{}{}); err == nil {
+		t.Error("Render без ключа Slug: ожидалась ошибка")
+	}
+}
@@ -0,0 +1,217 @@
+package policy
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/open-policy-agent/opa/cover"
+	"github.com/open-policy-agent/opa/storage"
+	"github.com/open-policy-agent/opa/tester"
+	"github.com/open-policy-agent/opa/topdown"
+)
+
+// TestResult результат одного правила test_* из файлов *_test.rego
+type TestResult struct {
+	// Package пакет теста без префикса data, например final_check_test
+	Package string
+	// Name имя правила теста
+	Name string
+	// Location файл и строка, в которых объявлен тест
+	Location string
+	Duration time.Duration
+
+	Fail bool
+	Skip bool
+	// Err ошибка выполнения теста (например, конфликт значений), в отличие от непройденной проверки
+	Err error
+	// FailedAt выражение, которое не выполнилось в упавшем тесте
+	FailedAt string
+	// Trace трассировка вычисления упавшего теста
+	Trace string
+	// Output вывод print() из политики и теста
+	Output string
+}
+
+// Pass сообщает, что тест пройден
+func (r TestResult) Pass() bool {
+	return !r.Fail && !r.Skip && r.Err == nil
+}
+
+// TestReport результаты тестов политик вместе с покрытием
+type TestReport struct {
+	Results []TestResult
+	// Coverage покрытие строк политик тестами. Файлы *_test.rego в отчет не входят.
+	Coverage cover.Report
+}
+
+// RunTests находит файлы *_test.rego по путям paths, загружает их вместе с политиками и данными
+// (так же, как opa test) и выполняет все правила test_*.
+func RunTests(ctx context.Context, paths ...string) (TestReport, error) {
+	modules, store, err := tester.Load(paths, testFilesOnly)
+	if err != nil {
+		return TestReport{}, fmt.Errorf("ошибка загрузки политик: %w", err)
+	}
+
+	txn, err := store.NewTransaction(ctx)
+	if err != nil {
+		return TestReport{}, fmt.Errorf("ошибка открытия транзакции: %w", err)
+	}
+	defer store.Abort(ctx, txn)
+
+	// В OPA трассировка и подсчет покрытия взаимоисключающие, поэтому сначала
+	// все тесты выполняются с подсчетом покрытия, а затем упавшие - повторно с трассировкой
+	cov := cover.New()
+	results, err := runTests(ctx, txn, tester.NewRunner().
+		SetStore(store).
+		SetModules(modules).
+		SetCoverageQueryTracer(cov).
+		CapturePrintOutput(true))
+	if err != nil {
+		return TestReport{}, err
+	}
+
+	var failed []string
+	for _, res := range results {
+		if res.Fail || res.Error != nil {
+			failed = append(failed, regexp.QuoteMeta(res.Package+"."+res.Name))
+		}
+	}
+
+	traces := make(map[string][]*topdown.Event)
+	if len(failed) > 0 {
+		traced, err := runTests(ctx, txn, tester.NewRunner().
+			SetStore(store).
+			SetModules(modules).
+			EnableTracing(true).
+			Filter("^("+strings.Join(failed, "|")+")$"))
+		if err != nil {
+			return TestReport{}, err
+		}
+
+		for _, res := range traced {
+			traces[res.Package+"."+res.Name] = res.Trace
+		}
+	}
+
+	report := TestReport{
+		Results:  make([]TestResult, 0, len(results)),
+		Coverage: coverageReport(cov, modules),
+	}
+	for _, res := range results {
+		report.Results = append(report.Results, newTestResult(res, traces[res.Package+"."+res.Name]))
+	}
+
+	return report, nil
+}
+
+func runTests(ctx context.Context, txn storage.Transaction, runner *tester.Runner) ([]*tester.Result, error) {
+	ch, err := runner.RunTests(ctx, txn)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка запуска тестов политик: %w", err)
+	}
+
+	var results []*tester.Result
+	for res := range ch {
+		results = append(results, res)
+	}
+
+	return results, nil
+}
+
+func newTestResult(res *tester.Result, trace []*topdown.Event) TestResult {
+	result := TestResult{
+		Package:  strings.TrimPrefix(res.Package, "data."),
+		Name:     res.Name,
+		Duration: res.Duration,
+		Fail:     res.Fail,
+		Skip:     res.Skip,
+		Err:      res.Error,
+		Output:   string(res.Output),
+	}
+
+	if res.Location != nil {
+		result.Location = fmt.Sprintf("%s:%d", res.Location.File, res.Location.Row)
+	}
+
+	if len(trace) > 0 {
+		var buf bytes.Buffer
+		topdown.PrettyTraceWithOpts(&buf, trace, topdown.PrettyTraceOptions{
+			Locations:     true,
+			ExprVariables: true,
+		})
+		result.Trace = buf.String()
+
+		if expr := failedAt(res.Location, trace); expr != nil {
+			result.FailedAt = fmt.Sprintf("%s:%d: %s", expr.Location.File, expr.Location.Row, expr)
+		}
+	}
+
+	return result
+}
+
+// failedAt ищет последнее невыполнившееся выражение в теле теста
+func failedAt(testLoc *ast.Location, trace []*topdown.Event) *ast.Expr {
+	if testLoc == nil {
+		return nil
+	}
+
+	for i := len(trace) - 1; i >= 0; i-- {
+		event := trace[i]
+		if event.Op != topdown.FailOp {
+			continue
+		}
+
+		expr, ok := event.Node.(*ast.Expr)
+		if ok && expr.Location != nil && expr.Location.File == testLoc.File {
+			return expr
+		}
+	}
+
+	return nil
+}
+
+// coverageReport считает покрытие только по политикам, без файлов *_test.rego
+func coverageReport(cov *cover.Cover, modules map[string]*ast.Module) cover.Report {
+	report := cov.Report(modules)
+
+	var covered, notCovered int
+	for file, fr := range report.Files {
+		if strings.HasSuffix(file, "_test.rego") {
+			delete(report.Files, file)
+			continue
+		}
+
+		covered += fr.CoveredLines
+		notCovered += fr.NotCoveredLines
+	}
+
+	report.CoveredLines = covered
+	report.NotCoveredLines = notCovered
+	report.Coverage = 0
+	if total := covered + notCovered; total > 0 {
+		report.Coverage = float64(covered) * 100 / float64(total)
+	}
+
+	return report
+}
+
+// testFilesOnly фильтр для загрузчика тестов: политики, тесты и данные
+func testFilesOnly(_ string, info fs.FileInfo, _ int) bool {
+	if info.IsDir() {
+		return false
+	}
+
+	switch filepath.Ext(info.Name()) {
+	case ".rego", ".json", ".yaml", ".yml":
+		return false
+	default:
+		return true
+	}
+}
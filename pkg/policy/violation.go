@@ -0,0 +1,25 @@
+package policy
+
+import "fmt"
+
+// Violation нарушение, из-за которого политика отказала в доступе.
+// Все проверки (ресурса, прав, ролей) возвращают нарушения в одном формате в поле violations.
+type Violation struct {
+	// Code машиночитаемый код нарушения, например resource_field_mismatch или permission_missing
+	Code string `rego:"code"`
+	// Field поле входных данных, к которому относится нарушение
+	Field string `rego:"field"`
+	// Expected ожидаемое значение в том виде, в котором его вернул OPA: строка, число, массив и т.д.
+	Expected interface{} `rego:"expected,optional"`
+	// Actual фактическое значение. nil, если поля нет во входных данных.
+	Actual interface{} `rego:"actual,optional"`
+	// Params дополнительные параметры для сообщения из каталога (Catalog), например действие для permission_missing
+	Params map[string]interface{} `rego:"params,optional"`
+	// Hint подсказка из политики. Используется, если в каталоге нет сообщения для кода нарушения.
+	Hint string `rego:"hint,optional"`
+}
+
+// String возвращает нарушение в виде строки для логов и консоли
+func (v Violation) String() string {
+	return fmt.Sprintf("%s (%s): ожидалось %v, получено %v", v.Field, v.Code, v.Expected, v.Actual)
+}
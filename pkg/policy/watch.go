@@ -0,0 +1,209 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// defaultDebounce время, в течение которого изменения файлов собираются в одну перезагрузку.
+// Редакторы часто сохраняют файл в несколько шагов (запись во временный файл, переименование).
+const defaultDebounce = 200 * time.Millisecond
+
+// Reloader перезагружает политики, например Engine
+type Reloader interface {
+	Reload(ctx context.Context) error
+}
+
+// ReloadHook вызывается после каждой перезагрузки. err != nil означает, что новая версия
+// политик не скомпилировалась и продолжает использоваться предыдущая.
+type ReloadHook func(err error)
+
+// Watcher следит за изменениями файлов с политиками и данными и перезагружает политики
+type Watcher struct {
+	paths    []string
+	target   Reloader
+	debounce time.Duration
+	hook     ReloadHook
+}
+
+// WatchOption настраивает Watcher
+type WatchOption func(w *Watcher)
+
+// WithDebounce задает время, в течение которого изменения собираются в одну перезагрузку
+func WithDebounce(d time.Duration) WatchOption {
+	return func(w *Watcher) {
+		w.debounce = d
+	}
+}
+
+// WithReloadHook задает обработчик результата перезагрузки.
+// По-умолчанию результат пишется в slog.
+func WithReloadHook(hook ReloadHook) WatchOption {
+	return func(w *Watcher) {
+		w.hook = hook
+	}
+}
+
+// NewWatcher создает наблюдателя за путями paths, который перезагружает target при изменениях.
+// Для движка пути можно получить через Engine.Paths.
+func NewWatcher(paths []string, target Reloader, opts ...WatchOption) *Watcher {
+	w := &Watcher{
+		paths:    paths,
+		target:   target,
+		debounce: defaultDebounce,
+		hook:     logReload,
+	}
+
+	for _, opt := range opts {
+		opt(w)
+	}
+
+	return w
+}
+
+// Run следит за изменениями, пока не будет отменен ctx
+func (w *Watcher) Run(ctx context.Context) error {
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("ошибка создания наблюдателя за файлами: %w", err)
+	}
+	defer fsw.Close()
+
+	// Отдельные файлы отслеживаются через родительскую директорию,
+	// иначе наблюдение теряется, когда редактор подменяет файл переименованием
+	files := make(map[string]struct{})
+	for _, path := range w.paths {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return fmt.Errorf("ошибка определения пути %s: %w", path, err)
+		}
+
+		info, err := os.Stat(abs)
+		if err != nil {
+			return fmt.Errorf("ошибка чтения %s: %w", path, err)
+		}
+
+		if !info.IsDir() {
+			files[abs] = struct{}{}
+			if err = fsw.Add(filepath.Dir(abs)); err != nil {
+				return fmt.Errorf("ошибка наблюдения за %s: %w", path, err)
+			}
+			continue
+		}
+
+		if err = addDirs(fsw, abs); err != nil {
+			return err
+		}
+	}
+
+	relevant := func(name string) bool {
+		if _, ok := files[name]; ok {
+			return true
+		}
+
+		return watchedFile(name) && w.inWatchedDir(name)
+	}
+
+	timer := time.NewTimer(w.debounce)
+	timer.Stop()
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case event, ok := <-fsw.Events:
+			if !ok {
+				return nil
+			}
+
+			// Новые поддиректории в отслеживаемых директориях тоже нужно отслеживать
+			if event.Has(fsnotify.Create) {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() && w.inWatchedDir(event.Name) {
+					if err = addDirs(fsw, event.Name); err != nil {
+						w.hook(err)
+					}
+					timer.Reset(w.debounce)
+					continue
+				}
+			}
+
+			if relevant(event.Name) {
+				timer.Reset(w.debounce)
+			}
+
+		case err, ok := <-fsw.Errors:
+			if !ok {
+				return nil
+			}
+			w.hook(fmt.Errorf("ошибка наблюдения за файлами: %w", err))
+
+		case <-timer.C:
+			w.hook(w.target.Reload(ctx))
+		}
+	}
+}
+
+// inWatchedDir проверяет, что name лежит внутри одной из отслеживаемых директорий
+func (w *Watcher) inWatchedDir(name string) bool {
+	for _, path := range w.paths {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			continue
+		}
+
+		rel, err := filepath.Rel(abs, name)
+		if err == nil && rel != "." && !strings.HasPrefix(rel, "..") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// watchedFile проверяет, что файл содержит политику или данные для нее
+func watchedFile(name string) bool {
+	switch filepath.Ext(name) {
+	case ".rego":
+		return !strings.HasSuffix(name, "_test.rego")
+	case ".json", ".yaml", ".yml":
+		return true
+	default:
+		return false
+	}
+}
+
+func addDirs(fsw *fsnotify.Watcher, root string) error {
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+
+		if err = fsw.Add(path); err != nil {
+			return fmt.Errorf("ошибка наблюдения за %s: %w", path, err)
+		}
+
+		return nil
+	})
+}
+
+func logReload(err error) {
+	if err != nil {
+		slog.Error("политики не перезагружены, используется предыдущая версия", slog.Any("error", err))
+		return
+	}
+
+	slog.Info("политики перезагружены")
+}
@@ -0,0 +1,122 @@
+package policy
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestWatcherKeepsLastGoodVersion проверяет, что наблюдатель перезагружает движок при изменении файлов,
+// а после записи политики, данных или схемы с ошибкой движок продолжает отвечать по предыдущей версии
+func TestWatcherKeepsLastGoodVersion(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	dir := t.TempDir()
+	policyPath := filepath.Join(dir, "authz.rego")
+	dataPath := filepath.Join(dir, "data.json")
+	schemaPath := filepath.Join(dir, "schema", "input.json")
+	if err := os.Mkdir(filepath.Dir(schemaPath), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	const (
+		policy = `package authz
+
+default allow = false
+
+allow {
+	data.roles[_] == input.role
+}
+`
+		data   = `{"roles": ["admin"]}`
+		schema = `{"type": "object", "properties": {"role": {"type": "string"}}}`
+	)
+	writeFile(t, policyPath, policy)
+	writeFile(t, dataPath, data)
+	writeFile(t, schemaPath, schema)
+
+	engine := New("data.authz.allow",
+		WithFiles(policyPath),
+		WithDataFiles(dataPath),
+		WithInputSchema(schemaPath),
+	)
+	if err := engine.Prepare(ctx); err != nil {
+		t.Fatalf("Prepare: %v", err)
+	}
+
+	reloads := make(chan error, 10)
+	watcher := NewWatcher(engine.Paths(), engine,
+		WithDebounce(10*time.Millisecond),
+		WithReloadHook(func(err error) { reloads <- err }),
+	)
+	go watcher.Run(ctx)
+	// Наблюдатель начинает отслеживать файлы не сразу после запуска
+	time.Sleep(50 * time.Millisecond)
+
+	broken := []struct {
+		path   string
+		source string
+		good   string
+	}{
+		{path: policyPath, source: "package authz\n\nallow {\n\tinput.role ==\n}\n", good: policy},
+		{path: dataPath, source: `{"roles": [`, good: data},
+		{path: schemaPath, source: `{"type": "object",`, good: schema},
+	}
+
+	for _, b := range broken {
+		writeFile(t, b.path, b.source)
+		if err := waitReload(t, reloads); err == nil {
+			t.Fatalf("%s с ошибкой перезагружен без ошибки", filepath.Base(b.path))
+		}
+		assertAllowed(t, engine, "admin", true)
+
+		writeFile(t, b.path, b.good)
+		if err := waitReload(t, reloads); err != nil {
+			t.Fatalf("ошибка при перезагрузке исправленного %s: %v", filepath.Base(b.path), err)
+		}
+	}
+
+	// Корректное изменение данных применяется
+	writeFile(t, dataPath, `{"roles": ["manager"]}`)
+	if err := waitReload(t, reloads); err != nil {
+		t.Fatalf("ошибка при перезагрузке данных: %v", err)
+	}
+	assertAllowed(t, engine, "admin", false)
+	assertAllowed(t, engine, "manager", true)
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("ошибка записи %s: %v", path, err)
+	}
+}
+
+func waitReload(t *testing.T, reloads <-chan error) error {
+	t.Helper()
+
+	select {
+	case err := <-reloads:
+		return err
+	case <-time.After(5 * time.Second):
+		t.Fatal("движок не перезагружен после изменения файла")
+		return nil
+	}
+}
+
+func assertAllowed(t *testing.T, engine *Engine, role string, want bool) {
+	t.Helper()
+
+	decision, err := engine.Eval(context.Background(), map[string]interface{}{"role": role})
+	if err != nil {
+		t.Fatalf("Eval для роли %s: %v", role, err)
+	}
+
+	if got, _ := decision.Bool(); got != want {
+		t.Errorf("роль %s: получено %v, ожидалось %v", role, got, want)
+	}
+}
@@ -0,0 +1,191 @@
+// Package rbac описывает иерархию ролей, которую политики получают через data.roles.
+package rbac
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// CycleError ошибка регистрации роли, которая через цепочку родителей наследовала бы сама себя
+type CycleError struct {
+	// Path цепочка ролей, замыкающая цикл, например [admin manager admin]
+	Path []string
+}
+
+func (e *CycleError) Error() string {
+	return "цикл в иерархии ролей: " + strings.Join(e.Path, " -> ")
+}
+
+// Roles иерархия ролей: каждая роль наследует права своих родителей и, транзитивно, их родителей.
+// Roles реализует policy.DataProvider, поэтому подключается к движку через
+// policy.WithDataProvider("roles", roles) и доступна в политике как data.roles.
+// Изменения иерархии попадают в политику при следующей компиляции движка (Engine.Reload).
+// Roles безопасен для конкурентного использования.
+type Roles struct {
+	mu      sync.RWMutex
+	parents map[string][]string
+}
+
+// role описание роли в data.roles
+type role struct {
+	Parents []string `json:"parents"`
+}
+
+// New создает пустую иерархию ролей
+func New() *Roles {
+	return &Roles{
+		parents: make(map[string][]string),
+	}
+}
+
+// LoadFile загружает иерархию из JSON-файла с данными для политик, в котором роли лежат в ключе roles:
+//
+//	{"roles": {"admin": {"parents": ["manager"]}, "manager": {"parents": []}}}
+func LoadFile(path string) (*Roles, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка загрузки ролей: %w", err)
+	}
+
+	var doc struct {
+		Roles map[string]role `json:"roles"`
+	}
+	if err = json.Unmarshal(raw, &doc); err != nil {
+		return nil, fmt.Errorf("ошибка загрузки ролей из %s: %w", path, err)
+	}
+
+	r := New()
+
+	// Роли регистрируются в порядке имен, чтобы при цикле ошибка всегда указывала на одну и ту же цепочку
+	names := make([]string, 0, len(doc.Roles))
+	for name := range doc.Roles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if err = r.Register(name, doc.Roles[name].Parents...); err != nil {
+			return nil, fmt.Errorf("ошибка загрузки ролей из %s: %w", path, err)
+		}
+	}
+
+	return r, nil
+}
+
+// Register добавляет роль с родителями или заменяет родителей уже зарегистрированной роли.
+// Родители, которые еще не зарегистрированы, добавляются как роли без родителей.
+// Если новая связь создает цикл, иерархия не меняется и возвращается *CycleError.
+func (r *Roles) Register(name string, parents ...string) error {
+	if name == "" {
+		return fmt.Errorf("имя роли не может быть пустым")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, parent := range parents {
+		if parent == "" {
+			return fmt.Errorf("роль %s: имя родителя не может быть пустым", name)
+		}
+
+		if path := r.pathLocked(parent, name); path != nil {
+			return &CycleError{Path: append([]string{name}, path...)}
+		}
+	}
+
+	r.parents[name] = append([]string(nil), parents...)
+	for _, parent := range parents {
+		if _, ok := r.parents[parent]; !ok {
+			r.parents[parent] = nil
+		}
+	}
+
+	return nil
+}
+
+// Parents возвращает непосредственных родителей роли
+func (r *Roles) Parents(name string) []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return append([]string(nil), r.parents[name]...)
+}
+
+// Effective возвращает роль вместе со всеми ролями, которые она наследует транзитивно, в порядке имен.
+// Так же эффективные роли вычисляет политика через graph.reachable.
+func (r *Roles) Effective(name string) []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	seen := map[string]bool{name: true}
+	queue := []string{name}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+
+		for _, parent := range r.parents[current] {
+			if !seen[parent] {
+				seen[parent] = true
+				queue = append(queue, parent)
+			}
+		}
+	}
+
+	roles := make([]string, 0, len(seen))
+	for role := range seen {
+		roles = append(roles, role)
+	}
+	sort.Strings(roles)
+
+	return roles
+}
+
+// Data возвращает иерархию в формате data.roles: {"admin": {"parents": ["manager"]}, ...}
+func (r *Roles) Data(_ context.Context) (interface{}, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	data := make(map[string]interface{}, len(r.parents))
+	for name, parents := range r.parents {
+		list := make([]interface{}, 0, len(parents))
+		for _, parent := range parents {
+			list = append(list, parent)
+		}
+
+		data[name] = map[string]interface{}{"parents": list}
+	}
+
+	return data, nil
+}
+
+// pathLocked ищет цепочку наследования от роли from до роли to, включая обе.
+// Возвращает nil, если to не достижима из from.
+func (r *Roles) pathLocked(from, to string) []string {
+	seen := make(map[string]bool)
+
+	var walk func(role string) []string
+	walk = func(role string) []string {
+		if role == to {
+			return []string{role}
+		}
+		if seen[role] {
+			return nil
+		}
+		seen[role] = true
+
+		for _, parent := range r.parents[role] {
+			if path := walk(parent); path != nil {
+				return append([]string{role}, path...)
+			}
+		}
+
+		return nil
+	}
+
+	return walk(from)
+}
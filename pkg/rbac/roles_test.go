@@ -0,0 +1,193 @@
+package rbac
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/olezhek28/access_policy/pkg/policy"
+)
+
+// newDiamond иерархия, в которой admin наследует employee через две цепочки
+func newDiamond(t *testing.T) *Roles {
+	t.Helper()
+
+	r := New()
+	for _, reg := range []struct {
+		name    string
+		parents []string
+	}{
+		{name: "admin", parents: []string{"manager", "auditor"}},
+		{name: "manager", parents: []string{"employee"}},
+		{name: "auditor", parents: []string{"employee"}},
+		{name: "employee"},
+	} {
+		if err := r.Register(reg.name, reg.parents...); err != nil {
+			t.Fatalf("Register(%s): %v", reg.name, err)
+		}
+	}
+
+	return r
+}
+
+func TestEffectiveDiamond(t *testing.T) {
+	r := newDiamond(t)
+
+	tests := []struct {
+		role string
+		want []string
+	}{
+		{role: "admin", want: []string{"admin", "auditor", "employee", "manager"}},
+		{role: "manager", want: []string{"employee", "manager"}},
+		{role: "employee", want: []string{"employee"}},
+		{role: "guest", want: []string{"guest"}},
+	}
+
+	for _, tt := range tests {
+		if got := r.Effective(tt.role); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Effective(%s) = %v, ожидалось %v", tt.role, got, tt.want)
+		}
+	}
+}
+
+func TestRegisterCycle(t *testing.T) {
+	tests := []struct {
+		name    string
+		role    string
+		parents []string
+		want    []string
+	}{
+		{name: "роль наследует сама себя", role: "employee", parents: []string{"employee"}, want: []string{"employee", "employee"}},
+		{name: "прямой цикл", role: "manager", parents: []string{"admin"}, want: []string{"manager", "admin", "manager"}},
+		{name: "цикл через цепочку", role: "employee", parents: []string{"admin"}, want: []string{"employee", "admin", "manager", "employee"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := New()
+			if err := r.Register("admin", "manager"); err != nil {
+				t.Fatal(err)
+			}
+			if err := r.Register("manager", "employee"); err != nil {
+				t.Fatal(err)
+			}
+
+			before := r.Parents(tt.role)
+
+			err := r.Register(tt.role, tt.parents...)
+			var cycleErr *CycleError
+			if !errors.As(err, &cycleErr) {
+				t.Fatalf("ошибка %v, ожидалась *CycleError", err)
+			}
+			if !reflect.DeepEqual(cycleErr.Path, tt.want) {
+				t.Errorf("цикл %v, ожидался %v", cycleErr.Path, tt.want)
+			}
+
+			// Связь с циклом не регистрируется
+			if got := r.Parents(tt.role); !reflect.DeepEqual(got, before) {
+				t.Errorf("родители %s после ошибки %v, ожидались %v", tt.role, got, before)
+			}
+		})
+	}
+}
+
+func TestRegisterUnknownParent(t *testing.T) {
+	r := New()
+	if err := r.Register("admin", "manager"); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+
+	// Незарегистрированный родитель становится ролью без родителей
+	data, err := r.Data(context.Background())
+	if err != nil {
+		t.Fatalf("Data: %v", err)
+	}
+	if _, ok := data.(map[string]interface{})["manager"]; !ok {
+		t.Errorf("родитель manager не добавлен в иерархию: %v", data)
+	}
+
+	// Позже у родителя могут появиться собственные родители
+	if err = r.Register("manager", "employee"); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	if got := r.Effective("admin"); !reflect.DeepEqual(got, []string{"admin", "employee", "manager"}) {
+		t.Errorf("Effective(admin) = %v", got)
+	}
+
+	if err = r.Register("admin", ""); err == nil {
+		t.Error("пустое имя родителя зарегистрировано без ошибки")
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	r, err := LoadFile("../../cmd/2_simple_policy_in_file/roles.json")
+	if err != nil {
+		t.Fatalf("LoadFile: %v", err)
+	}
+	if got := r.Effective("admin"); !reflect.DeepEqual(got, []string{"admin", "employee", "manager"}) {
+		t.Errorf("Effective(admin) = %v", got)
+	}
+
+	path := filepath.Join(t.TempDir(), "roles.json")
+	if err = os.WriteFile(path, []byte(`{"roles": {"admin": {"parents": ["manager"]}, "manager": {"parents": ["admin"]}}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var cycleErr *CycleError
+	if _, err = LoadFile(path); !errors.As(err, &cycleErr) {
+		t.Fatalf("ошибка %v, ожидалась *CycleError", err)
+	}
+	// Роли регистрируются в порядке имен, поэтому цикл всегда обнаруживается на роли manager
+	if fmt.Sprint(cycleErr.Path) != "[manager admin manager]" {
+		t.Errorf("цикл %v", cycleErr.Path)
+	}
+}
+
+// TestDataInPolicy проверяет, что политика по data.roles вычисляет те же эффективные роли, что и Effective
+func TestDataInPolicy(t *testing.T) {
+	ctx := context.Background()
+	r := newDiamond(t)
+
+	data, err := r.Data(ctx)
+	if err != nil {
+		t.Fatalf("Data: %v", err)
+	}
+	want := map[string]interface{}{
+		"admin":    map[string]interface{}{"parents": []interface{}{"manager", "auditor"}},
+		"manager":  map[string]interface{}{"parents": []interface{}{"employee"}},
+		"auditor":  map[string]interface{}{"parents": []interface{}{"employee"}},
+		"employee": map[string]interface{}{"parents": []interface{}{}},
+	}
+	if !reflect.DeepEqual(data, want) {
+		t.Errorf("Data() = %v, ожидалось %v", data, want)
+	}
+
+	engine := policy.New("data.roles_test.effective",
+		policy.WithModule("roles_test.rego", `package roles_test
+
+role_graph := {role: data.roles[role].parents | data.roles[role]}
+
+effective := sort(graph.reachable(role_graph, {input.role}))
+`),
+		policy.WithDataProvider("roles", r),
+	)
+
+	for _, role := range []string{"admin", "manager", "employee"} {
+		decision, err := engine.Eval(ctx, map[string]interface{}{"role": role})
+		if err != nil {
+			t.Fatalf("Eval(%s): %v", role, err)
+		}
+
+		var got []string
+		for _, v := range decision.Value.([]interface{}) {
+			got = append(got, v.(string))
+		}
+		if want := r.Effective(role); !reflect.DeepEqual(got, want) {
+			t.Errorf("роль %s: политика вычислила %v, Effective %v", role, got, want)
+		}
+	}
+}